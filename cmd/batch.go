@@ -0,0 +1,307 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/agarmu/datamine-scraper/render"
+	"github.com/agarmu/datamine-scraper/scrape"
+	"github.com/spf13/cobra"
+)
+
+var (
+	batchFrom string
+	batchOut  string
+	batchJobs int
+)
+
+// batchCmd represents the batch command
+var batchCmd = &cobra.Command{
+	Use:   "batch [URL | FILE...]",
+	Short: "Generate the skeletons of many projects at once",
+	Long: `Generates a skeleton for every project of a course and term found on the
+index pages of the Examples Book (--course and --term), for every url listed in
+a file (--from, one per line, - for standard input), and for every url or file
+given as an argument.
+
+Several pages are scraped at the same time (--jobs). Every skeleton is named
+after the filename pattern and written to --out; the project number, course
+and term are taken from each page.`,
+	Example: `$ tdmscrape batch --course 20100 --term 2023 --out ./projects/ --name "First Last"`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyConfigFile(cmd); err != nil {
+			return err
+		}
+		if err := applyEnvironment(cmd); err != nil {
+			return err
+		}
+		if globalConfig.name == "" {
+			return fmt.Errorf("missing name (--name, %s or name in the configuration file)", envName)
+		}
+		if batchJobs < 1 {
+			return fmt.Errorf("--jobs must be at least 1")
+		}
+		format, err := outputFormat("")
+		if err != nil {
+			return err
+		}
+		if _, err := renderer(format); err != nil {
+			return err
+		}
+		if _, err := imageMode(format); err != nil {
+			return err
+		}
+		if err := loadTemplate(); err != nil {
+			return err
+		}
+		// one scraper for all, so that they share its rate limit and the
+		// robots.txt it read
+		scraper := newScraper(false)
+		sources, err := batchSources(cmd.Context(), scraper, args)
+		if err != nil {
+			return err
+		}
+		if len(sources) == 0 {
+			return errors.New("nothing to generate: give urls, --from, or --course and --term")
+		}
+		out := batchOut
+		if out == "" {
+			out = globalConfig.outputDir
+		}
+		if out == "" {
+			out = "."
+		}
+		if err := os.MkdirAll(out, 0755); err != nil {
+			return err
+		}
+		return runBatch(cmd.Context(), scraper, sources, out)
+	},
+}
+
+// batchSources collects the sources given in every way, each once.
+func batchSources(ctx context.Context, scraper *scrape.Scraper, args []string) ([]string, error) {
+	sources := append([]string{}, args...)
+	if batchFrom != "" {
+		listed, err := readSources(batchFrom)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, listed...)
+	}
+	if catalogCourse != "" || catalogTerm != "" {
+		entries, err := catalog(ctx, scraper)
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) == 0 {
+			return nil, fmt.Errorf("no projects found on %s for the course and term given", catalogIndex)
+		}
+		for _, e := range entries {
+			sources = append(sources, e.URL)
+		}
+	}
+	unique := []string{}
+	seen := map[string]bool{}
+	for _, s := range sources {
+		if !seen[s] {
+			seen[s] = true
+			unique = append(unique, s)
+		}
+	}
+	return unique, nil
+}
+
+// readSources reads a list of sources, one per line. Blank lines and lines
+// starting with # are skipped.
+func readSources(path string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		r = file
+	}
+	var sources []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			sources = append(sources, line)
+		}
+	}
+	return sources, scanner.Err()
+}
+
+// batchResult is the outcome of generating one skeleton.
+type batchResult struct {
+	source   string
+	path     string
+	warnings []string
+	err      error
+}
+
+// runBatch generates the skeletons with a pool of workers, printing a line
+// for every project as it is started and another as it is done.
+func runBatch(ctx context.Context, scraper *scrape.Scraper, sources []string, out string) error {
+	jobs := make(chan string)
+	started := make(chan string)
+	results := make(chan batchResult)
+	paths := &claimedPaths{claimed: map[string]string{}}
+	var wg sync.WaitGroup
+	for i := 0; i < batchJobs && i < len(sources); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for source := range jobs {
+				started <- source
+				results <- generateOne(ctx, scraper, source, out, paths)
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for _, s := range sources {
+			select {
+			case jobs <- s:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	done, failed, running := 0, 0, 0
+	width := len(fmt.Sprint(len(sources)))
+	// projects in progress are listed without a count, lined up with those
+	// that are done
+	indent := len(fmt.Sprintf("[%d/%d]", len(sources), len(sources)))
+loop:
+	for {
+		select {
+		case source := <-started:
+			running++
+			fmt.Printf("%*s started %s (%d running)\n", indent, "", source, running)
+		case r, ok := <-results:
+			if !ok {
+				break loop
+			}
+			running--
+			done++
+			progress := fmt.Sprintf("[%*d/%d]", width, done, len(sources))
+			if r.err != nil {
+				failed++
+				fmt.Fprintf(os.Stderr, "%s failed %s: %s\n", progress, r.source, r.err)
+			} else {
+				fmt.Printf("%s wrote %s\n", progress, r.path)
+			}
+			for _, w := range r.warnings {
+				fmt.Fprintf(os.Stderr, "%*s Warning: %s\n", len(progress), "", w)
+			}
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d projects failed", failed, len(sources))
+	}
+	fmt.Printf("Generated %d skeletons in %s.\n", len(sources), out)
+	return nil
+}
+
+// claimedPaths makes sure that no two projects of a batch are written to
+// the same file.
+type claimedPaths struct {
+	mu      sync.Mutex
+	claimed map[string]string
+}
+
+func (c *claimedPaths) claim(path string, source string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if other, ok := c.claimed[path]; ok {
+		return fmt.Errorf("%s is also written to %s; add {course} and {term} to the filename pattern", other, path)
+	}
+	c.claimed[path] = source
+	return nil
+}
+
+// generateOne scrapes a single project and writes its skeleton. It only
+// reads the global configuration, so that any number can run at once.
+func generateOne(ctx context.Context, scraper *scrape.Scraper, source string, out string, paths *claimedPaths) batchResult {
+	r := batchResult{source: source}
+	scraper = scraper.WithWarn(func(err error) {
+		r.warnings = append(r.warnings, err.Error())
+	})
+	project, err := scraper.Scrape(ctx, source)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	ropts := renderOptions()
+	if ropts.Images != render.ImagesSkip {
+		if err := scraper.FetchImages(ctx, project); err != nil {
+			r.warnings = append(r.warnings, strings.Split(err.Error(), "\n")...)
+		}
+	}
+	id := project.Identify()
+	if id.Number <= 0 {
+		r.err = errors.New("could not tell the project number from the page")
+		return r
+	}
+	ropts.ProjectNumber, ropts.Course, ropts.Term = id.Number, id.Course, id.Term
+	filename, err := outputFilename(id.Number, id.Course, id.Term)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	path, err := filepath.Abs(filepath.Join(out, filename))
+	if err == nil {
+		err = paths.claim(path, source)
+	}
+	if err == nil {
+		path, err = checkOutputPath(path)
+	}
+	if err == nil {
+		err = writeProject(project, path, ropts)
+	}
+	r.path, r.err = path, err
+	return r
+}
+
+func init() {
+	rootCmd.AddCommand(batchCmd)
+	batchCmd.Flags().StringVar(&catalogCourse, "course", "", "generate the projects of this course, e.g. 20100")
+	batchCmd.Flags().StringVar(&batchFrom, "from", "", "file listing the urls to generate, one per line (- for standard input)")
+	batchCmd.Flags().StringVar(&batchOut, "out", "", "directory to write the skeletons to (default output_dir from the configuration file, else the current directory)")
+	batchCmd.Flags().IntVarP(&batchJobs, "jobs", "j", 4, "number of pages to scrape at the same time")
+	addCatalogFlags(batchCmd)
+	addLayoutFlags(batchCmd)
+}
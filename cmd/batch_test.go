@@ -0,0 +1,93 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/agarmu/datamine-scraper/scrape"
+)
+
+func TestBatchSources(t *testing.T) {
+	list := filepath.Join(t.TempDir(), "urls.txt")
+	contents := "# project pages\nhttps://example.com/p2\n\n  https://example.com/p1  \nhttps://example.com/p3\n"
+	if err := os.WriteFile(list, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		args []string
+		from string
+		want []string
+	}{
+		{
+			name: "arguments",
+			args: []string{"https://example.com/p1", "https://example.com/p2", "https://example.com/p1"},
+			want: []string{"https://example.com/p1", "https://example.com/p2"},
+		},
+		{
+			name: "list",
+			from: list,
+			want: []string{"https://example.com/p2", "https://example.com/p1", "https://example.com/p3"},
+		},
+		{
+			name: "arguments and list",
+			args: []string{"https://example.com/p3", "https://example.com/p4"},
+			from: list,
+			want: []string{"https://example.com/p3", "https://example.com/p4", "https://example.com/p2", "https://example.com/p1"},
+		},
+	}
+	defer func(from, course, term string) {
+		batchFrom, catalogCourse, catalogTerm = from, course, term
+	}(batchFrom, catalogCourse, catalogTerm)
+	catalogCourse, catalogTerm = "", ""
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batchFrom = tt.from
+			got, err := batchSources(context.Background(), scrape.New(scrape.Options{}), tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClaimedPaths(t *testing.T) {
+	tests := []struct {
+		path, source string
+		ok           bool
+	}{
+		{"/out/first-project01.ipynb", "https://example.com/10100-2023-project01", true},
+		{"/out/first-project02.ipynb", "https://example.com/10100-2023-project02", true},
+		{"/out/first-project01.ipynb", "https://example.com/20100-2023-project01", false},
+		{"/out/first-project01.Rmd", "https://example.com/20100-2023-project01", true},
+	}
+	paths := &claimedPaths{claimed: map[string]string{}}
+	for _, tt := range tests {
+		err := paths.claim(tt.path, tt.source)
+		if (err == nil) != tt.ok {
+			t.Errorf("claiming %s for %s: got %v, want ok = %v", tt.path, tt.source, err, tt.ok)
+		}
+	}
+}
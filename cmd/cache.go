@@ -0,0 +1,77 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/agarmu/datamine-scraper/scrape"
+	"github.com/spf13/cobra"
+)
+
+// cacheCmd represents the cache command
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the cache of downloaded project pages",
+}
+
+var cacheLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List cached project pages",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		entries, err := scrape.ListCache()
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			fmt.Println("The cache is empty.")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "FETCHED\tSIZE\tURL")
+		for _, e := range entries {
+			fmt.Fprintf(w, "%s\t%d\t%s\n", e.Fetched.Format(time.DateTime), e.Size, e.URL)
+		}
+		return w.Flush()
+	},
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear [URL...]",
+	Short: "Remove cached project pages (all of them and their images if no url is given)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return scrape.ClearCache()
+		}
+		for _, url := range args {
+			if err := scrape.RemoveFromCache(url); err != nil {
+				return fmt.Errorf("%s: %w", url, err)
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheLsCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+}
@@ -0,0 +1,161 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage the configuration file",
+	Long: `Manages the configuration file holding default values for tdmscrape.
+
+Values given on the command line take precedence over the configuration file.
+With --profile, values are read from and written to the named profile, which
+overrides the top-level values of the file.
+
+Available keys: ` + strings.Join(settingKeyNames(), ", "),
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get [KEY]",
+	Short: "Print a configuration value, or all of them if no key is given",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cf, err := loadConfigFile()
+		if err != nil {
+			return err
+		}
+		s, err := cf.settings(globalConfig.profile)
+		if err != nil {
+			return err
+		}
+		if len(args) == 0 {
+			data, err := marshalYAML(s)
+			if err != nil {
+				return err
+			}
+			fmt.Print(string(data))
+			return nil
+		}
+		accessor, ok := settingKeys[args[0]]
+		if !ok {
+			return fmt.Errorf("unknown key %q", args[0])
+		}
+		fmt.Println(accessor.get(&s))
+		return nil
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set KEY VALUE",
+	Short: "Store a configuration value (an empty value removes it)",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		accessor, ok := settingKeys[args[0]]
+		if !ok {
+			return fmt.Errorf("unknown key %q", args[0])
+		}
+		cf, err := loadConfigFile()
+		if err != nil {
+			return err
+		}
+		if globalConfig.profile == "" {
+			err = accessor.set(&cf.Settings, args[1])
+		} else {
+			if cf.Profiles == nil {
+				cf.Profiles = map[string]Settings{}
+			}
+			p := cf.Profiles[globalConfig.profile]
+			err = accessor.set(&p, args[1])
+			cf.Profiles[globalConfig.profile] = p
+		}
+		if err != nil {
+			return err
+		}
+		return saveConfigFile(cf)
+	},
+}
+
+var configEditCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Open the configuration file in your editor",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := configPath()
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			if err := saveConfigFile(ConfigFile{}); err != nil {
+				return err
+			}
+		}
+		editor := os.Getenv("VISUAL")
+		if editor == "" {
+			editor = os.Getenv("EDITOR")
+		}
+		if editor == "" {
+			editor = "vi"
+			if runtime.GOOS == "windows" {
+				editor = "notepad"
+			}
+		}
+		// the editor variable may carry arguments, e.g. "code --wait"
+		parts := strings.Fields(editor)
+		c := exec.Command(parts[0], append(parts[1:], path)...)
+		c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if err := c.Run(); err != nil {
+			return err
+		}
+		if _, err := loadConfigFile(); err != nil {
+			return fmt.Errorf("the configuration file is no longer valid: %w", err)
+		}
+		return nil
+	},
+}
+
+var configPathCmd = &cobra.Command{
+	Use:   "path",
+	Short: "Print the location of the configuration file",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := configPath()
+		if err != nil {
+			return err
+		}
+		fmt.Println(path)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configEditCmd)
+	configCmd.AddCommand(configPathCmd)
+}
@@ -0,0 +1,422 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agarmu/datamine-scraper/scrape"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Settings are the values that can be stored in the configuration file,
+// either at the top level or inside a profile.
+type Settings struct {
+	Name                     string               `yaml:"name,omitempty"`
+	OutputDir                string               `yaml:"output_dir,omitempty"`
+	FilenamePattern          string               `yaml:"filename_pattern,omitempty"`
+	Kernel                   string               `yaml:"kernel,omitempty"`
+	Format                   string               `yaml:"format,omitempty"`
+	Template                 string               `yaml:"template,omitempty"`
+	Images                   string               `yaml:"images,omitempty"`
+	SubSubQuestionsOwnBlocks *bool                `yaml:"sub_sub_questions_own_blocks,omitempty"`
+	Starters                 *bool                `yaml:"starters,omitempty"`
+	CheckDatasets            *bool                `yaml:"check_datasets,omitempty"`
+	Timeout                  string               `yaml:"timeout,omitempty"`
+	Retries                  *int                 `yaml:"retries,omitempty"`
+	Delay                    string               `yaml:"delay,omitempty"`
+	UserAgent                string               `yaml:"user_agent,omitempty"`
+	IgnoreRobots             *bool                `yaml:"ignore_robots,omitempty"`
+	UpdateCheck              *bool                `yaml:"update_check,omitempty"`
+	TAHelp                   []string             `yaml:"ta_help,omitempty"`
+	Collaborators            []string             `yaml:"collaborators,omitempty"`
+	RewriteRules             []scrape.RewriteRule `yaml:"rewrite_rules,omitempty"`
+}
+
+// ConfigFile is the on-disk configuration: default settings plus named
+// profiles (usually one per course) that override them.
+type ConfigFile struct {
+	Settings `yaml:",inline"`
+	Profiles map[string]Settings `yaml:"profiles,omitempty"`
+}
+
+// merge returns s with every value that is set in o taking precedence.
+func (s Settings) merge(o Settings) Settings {
+	if o.Name != "" {
+		s.Name = o.Name
+	}
+	if o.OutputDir != "" {
+		s.OutputDir = o.OutputDir
+	}
+	if o.FilenamePattern != "" {
+		s.FilenamePattern = o.FilenamePattern
+	}
+	if o.Kernel != "" {
+		s.Kernel = o.Kernel
+	}
+	if o.Format != "" {
+		s.Format = o.Format
+	}
+	if o.Template != "" {
+		s.Template = o.Template
+	}
+	if o.Images != "" {
+		s.Images = o.Images
+	}
+	if o.SubSubQuestionsOwnBlocks != nil {
+		s.SubSubQuestionsOwnBlocks = o.SubSubQuestionsOwnBlocks
+	}
+	if o.Starters != nil {
+		s.Starters = o.Starters
+	}
+	if o.CheckDatasets != nil {
+		s.CheckDatasets = o.CheckDatasets
+	}
+	if o.Timeout != "" {
+		s.Timeout = o.Timeout
+	}
+	if o.Retries != nil {
+		s.Retries = o.Retries
+	}
+	if o.Delay != "" {
+		s.Delay = o.Delay
+	}
+	if o.UserAgent != "" {
+		s.UserAgent = o.UserAgent
+	}
+	if o.IgnoreRobots != nil {
+		s.IgnoreRobots = o.IgnoreRobots
+	}
+	if o.UpdateCheck != nil {
+		s.UpdateCheck = o.UpdateCheck
+	}
+	if o.TAHelp != nil {
+		s.TAHelp = o.TAHelp
+	}
+	if o.Collaborators != nil {
+		s.Collaborators = o.Collaborators
+	}
+	if o.RewriteRules != nil {
+		s.RewriteRules = o.RewriteRules
+	}
+	return s
+}
+
+type settingAccessor struct {
+	get func(s *Settings) string
+	set func(s *Settings, value string) error
+}
+
+func listValue(l []string) string {
+	return strings.Join(l, ", ")
+}
+
+func parseList(value string) []string {
+	l := []string{}
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			l = append(l, v)
+		}
+	}
+	return l
+}
+
+// boolSetting accesses a boolean setting, which is unset when empty.
+func boolSetting(field func(s *Settings) **bool) settingAccessor {
+	return settingAccessor{
+		get: func(s *Settings) string {
+			if *field(s) == nil {
+				return ""
+			}
+			return strconv.FormatBool(**field(s))
+		},
+		set: func(s *Settings, v string) error {
+			if v == "" {
+				*field(s) = nil
+				return nil
+			}
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("%q is not a boolean", v)
+			}
+			*field(s) = &b
+			return nil
+		},
+	}
+}
+
+// durationSetting accesses a setting holding a duration such as 30s.
+func durationSetting(field func(s *Settings) *string) settingAccessor {
+	return settingAccessor{
+		get: func(s *Settings) string { return *field(s) },
+		set: func(s *Settings, v string) error {
+			if v != "" {
+				if _, err := time.ParseDuration(v); err != nil {
+					return fmt.Errorf("%q is not a duration such as 30s or 1m", v)
+				}
+			}
+			*field(s) = v
+			return nil
+		},
+	}
+}
+
+// settingKeys maps the keys used by `tdmscrape config get/set` to fields.
+var settingKeys = map[string]settingAccessor{
+	"name": {
+		get: func(s *Settings) string { return s.Name },
+		set: func(s *Settings, v string) error { s.Name = v; return nil },
+	},
+	"output_dir": {
+		get: func(s *Settings) string { return s.OutputDir },
+		set: func(s *Settings, v string) error { s.OutputDir = v; return nil },
+	},
+	"filename_pattern": {
+		get: func(s *Settings) string { return s.FilenamePattern },
+		set: func(s *Settings, v string) error { s.FilenamePattern = v; return nil },
+	},
+	"kernel": {
+		get: func(s *Settings) string { return s.Kernel },
+		set: func(s *Settings, v string) error { s.Kernel = v; return nil },
+	},
+	"format": {
+		get: func(s *Settings) string { return s.Format },
+		set: func(s *Settings, v string) error { s.Format = v; return nil },
+	},
+	"template": {
+		get: func(s *Settings) string { return s.Template },
+		set: func(s *Settings, v string) error { s.Template = v; return nil },
+	},
+	"images": {
+		get: func(s *Settings) string { return s.Images },
+		set: func(s *Settings, v string) error { s.Images = v; return nil },
+	},
+	"sub_sub_questions_own_blocks": boolSetting(func(s *Settings) **bool { return &s.SubSubQuestionsOwnBlocks }),
+	"starters":                     boolSetting(func(s *Settings) **bool { return &s.Starters }),
+	"check_datasets":               boolSetting(func(s *Settings) **bool { return &s.CheckDatasets }),
+	"timeout":                      durationSetting(func(s *Settings) *string { return &s.Timeout }),
+	"delay":                        durationSetting(func(s *Settings) *string { return &s.Delay }),
+	"retries": {
+		get: func(s *Settings) string {
+			if s.Retries == nil {
+				return ""
+			}
+			return strconv.Itoa(*s.Retries)
+		},
+		set: func(s *Settings, v string) error {
+			if v == "" {
+				s.Retries = nil
+				return nil
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return fmt.Errorf("%q is not a number of retries", v)
+			}
+			s.Retries = &n
+			return nil
+		},
+	},
+	"user_agent": {
+		get: func(s *Settings) string { return s.UserAgent },
+		set: func(s *Settings, v string) error { s.UserAgent = v; return nil },
+	},
+	"ignore_robots": boolSetting(func(s *Settings) **bool { return &s.IgnoreRobots }),
+	"update_check":  boolSetting(func(s *Settings) **bool { return &s.UpdateCheck }),
+	"ta_help": {
+		get: func(s *Settings) string { return listValue(s.TAHelp) },
+		set: func(s *Settings, v string) error { s.TAHelp = parseList(v); return nil },
+	},
+	"collaborators": {
+		get: func(s *Settings) string { return listValue(s.Collaborators) },
+		set: func(s *Settings, v string) error { s.Collaborators = parseList(v); return nil },
+	},
+}
+
+func settingKeyNames() []string {
+	keys := make([]string, 0, len(settingKeys))
+	for k := range settingKeys {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// configPath returns the location of the configuration file, honouring
+// $XDG_CONFIG_HOME through os.UserConfigDir.
+func configPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "tdmscrape", "config.yaml"), nil
+}
+
+// loadConfigFile reads the configuration file. A missing file is not an
+// error and yields an empty configuration.
+func loadConfigFile() (ConfigFile, error) {
+	var cf ConfigFile
+	path, err := configPath()
+	if err != nil {
+		return cf, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cf, nil
+	} else if err != nil {
+		return cf, err
+	}
+	if err := yaml.Unmarshal(data, &cf); err != nil {
+		return cf, fmt.Errorf("%s: %w", path, err)
+	}
+	return cf, nil
+}
+
+func saveConfigFile(cf ConfigFile) error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := marshalYAML(cf)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func marshalYAML(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// settings returns the effective settings for the given profile.
+func (cf ConfigFile) settings(profile string) (Settings, error) {
+	if profile == "" {
+		return cf.Settings, nil
+	}
+	p, ok := cf.Profiles[profile]
+	if !ok {
+		return Settings{}, fmt.Errorf("no profile named %q in the configuration file", profile)
+	}
+	return cf.Settings.merge(p), nil
+}
+
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, path[1:]), nil
+}
+
+// applyConfigFile copies settings from the configuration file into
+// globalConfig for every flag that was not given on the command line.
+func applyConfigFile(cmd *cobra.Command) error {
+	cf, err := loadConfigFile()
+	if err != nil {
+		return err
+	}
+	s, err := cf.settings(globalConfig.profile)
+	if err != nil {
+		return err
+	}
+	flags := cmd.Flags()
+	unset := func(name string) bool {
+		return flags.Lookup(name) == nil || !flags.Changed(name)
+	}
+	if s.Name != "" && unset("name") {
+		globalConfig.name = s.Name
+	}
+	if s.SubSubQuestionsOwnBlocks != nil && unset("sub-sub-questions-own-blocks") {
+		globalConfig.subsubquestionsOwnCodeBlocks = *s.SubSubQuestionsOwnBlocks
+	}
+	if s.Images != "" && unset("images") {
+		globalConfig.images = s.Images
+	}
+	if s.Starters != nil && unset("starters") {
+		globalConfig.starters = *s.Starters
+	}
+	if s.CheckDatasets != nil && unset("check-datasets") {
+		globalConfig.checkDatasets = *s.CheckDatasets
+	}
+	if s.Timeout != "" && unset("timeout") {
+		if globalConfig.timeout, err = time.ParseDuration(s.Timeout); err != nil {
+			return fmt.Errorf("timeout in the configuration file: %w", err)
+		}
+	}
+	if s.Retries != nil && unset("retries") {
+		globalConfig.retries = *s.Retries
+	}
+	if s.Delay != "" && unset("delay") {
+		if globalConfig.delay, err = time.ParseDuration(s.Delay); err != nil {
+			return fmt.Errorf("delay in the configuration file: %w", err)
+		}
+	}
+	if s.UserAgent != "" && unset("user-agent") {
+		globalConfig.userAgent = s.UserAgent
+	}
+	if s.IgnoreRobots != nil && unset("ignore-robots") {
+		globalConfig.ignoreRobots = *s.IgnoreRobots
+	}
+	if s.OutputDir != "" {
+		globalConfig.outputDir, err = expandHome(s.OutputDir)
+		if err != nil {
+			return err
+		}
+	}
+	if s.FilenamePattern != "" {
+		globalConfig.filenamePattern = s.FilenamePattern
+	}
+	if s.Kernel != "" && unset("kernel") {
+		globalConfig.kernel = s.Kernel
+	}
+	if s.Format != "" && unset("format") {
+		globalConfig.format = s.Format
+	}
+	if s.Template != "" && unset("template") {
+		globalConfig.templatePath, err = expandHome(s.Template)
+		if err != nil {
+			return err
+		}
+	}
+	globalConfig.taHelp = s.TAHelp
+	globalConfig.collaborators = s.Collaborators
+	globalConfig.rewriteRules = s.RewriteRules
+	return nil
+}
@@ -0,0 +1,183 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func TestSettingsMerge(t *testing.T) {
+	yes, no, three := true, false, 3
+	base := Settings{
+		Name:          "First Last",
+		Kernel:        "f2023-s2024",
+		Starters:      &yes,
+		Retries:       &three,
+		TAHelp:        []string{"A TA"},
+		Collaborators: []string{"A Friend"},
+	}
+	profile := Settings{
+		Kernel:        "ir",
+		Starters:      &no,
+		Collaborators: []string{},
+	}
+	got := base.merge(profile)
+	want := Settings{
+		Name:          "First Last",
+		Kernel:        "ir",
+		Starters:      &no,
+		Retries:       &three,
+		TAHelp:        []string{"A TA"},
+		Collaborators: []string{},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if got := base.merge(Settings{}); !reflect.DeepEqual(got, base) {
+		t.Errorf("merging nothing: got %+v, want %+v", got, base)
+	}
+}
+
+const testConfigFile = `name: Config Name
+kernel: f2023-s2024
+retries: 5
+delay: 2s
+starters: true
+profiles:
+  r:
+    kernel: ir
+    name: Profile Name
+    starters: false
+`
+
+// testConfig writes the configuration file and restores globalConfig after
+// the test.
+func testConfig(t *testing.T, contents string) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	// unset for the test, and restored after it
+	t.Setenv(envName, "")
+	os.Unsetenv(envName)
+	path := filepath.Join(dir, "tdmscrape", "config.yaml")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	saved := globalConfig
+	t.Cleanup(func() { globalConfig = saved })
+}
+
+func TestApplyConfigFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		env      string
+		userName string
+		kernel   string
+		retries  int
+		delay    time.Duration
+		starters bool
+	}{
+		{
+			name:     "defaults",
+			userName: "Config Name", kernel: "f2023-s2024", retries: 5, delay: 2 * time.Second, starters: true,
+		},
+		{
+			name:     "profile",
+			args:     []string{"--profile", "r"},
+			userName: "Profile Name", kernel: "ir", retries: 5, delay: 2 * time.Second, starters: false,
+		},
+		{
+			name:     "flags",
+			args:     []string{"--profile", "r", "--name", "Flag Name", "--kernel", "python3", "--retries", "0", "--starters"},
+			userName: "Flag Name", kernel: "python3", retries: 0, delay: 2 * time.Second, starters: true,
+		},
+		{
+			name:     "environment",
+			args:     []string{"--profile", "r"},
+			env:      "Env Name",
+			userName: "Env Name", kernel: "ir", retries: 5, delay: 2 * time.Second, starters: false,
+		},
+		{
+			name:     "flags over environment",
+			args:     []string{"--name", "Flag Name"},
+			env:      "Env Name",
+			userName: "Flag Name", kernel: "f2023-s2024", retries: 5, delay: 2 * time.Second, starters: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testConfig(t, testConfigFile)
+			if tt.env != "" {
+				t.Setenv(envName, tt.env)
+			}
+			cmd := &cobra.Command{}
+			cmd.Flags().StringVar(&globalConfig.profile, "profile", "", "")
+			addGenerateFlags(cmd)
+			if err := cmd.ParseFlags(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if err := applyConfigFile(cmd); err != nil {
+				t.Fatal(err)
+			}
+			if err := applyEnvironment(cmd); err != nil {
+				t.Fatal(err)
+			}
+			c := globalConfig
+			if c.name != tt.userName || c.kernel != tt.kernel || c.retries != tt.retries || c.delay != tt.delay || c.starters != tt.starters {
+				t.Errorf("got name %q, kernel %q, retries %d, delay %s, starters %t; want %q, %q, %d, %s, %t",
+					c.name, c.kernel, c.retries, c.delay, c.starters,
+					tt.userName, tt.kernel, tt.retries, tt.delay, tt.starters)
+			}
+		})
+	}
+}
+
+func TestApplyConfigFileErrors(t *testing.T) {
+	tests := []struct {
+		name, contents string
+		args           []string
+	}{
+		{"unknown profile", testConfigFile, []string{"--profile", "python"}},
+		{"bad delay", "delay: soon\n", nil},
+		{"bad yaml", "name: [\n", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testConfig(t, tt.contents)
+			cmd := &cobra.Command{}
+			cmd.Flags().StringVar(&globalConfig.profile, "profile", "", "")
+			addGenerateFlags(cmd)
+			if err := cmd.ParseFlags(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if err := applyConfigFile(cmd); err == nil {
+				t.Error("got no error")
+			}
+		})
+	}
+}
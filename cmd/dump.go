@@ -0,0 +1,130 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/agarmu/datamine-scraper/model"
+	"github.com/agarmu/datamine-scraper/scrape"
+	"github.com/spf13/cobra"
+)
+
+// dumpSchemaVersion is bumped whenever the dump format changes in a way that
+// is not backwards compatible. Adding fields is not such a change.
+const dumpSchemaVersion = 1
+
+// Dump is the document written by `tdmscrape dump`. See the README for a
+// description of every field.
+type Dump struct {
+	Schema    int              `json:"schema" yaml:"schema"`
+	Page      PageInfo         `json:"page" yaml:"page"`
+	Questions []model.Question `json:"questions" yaml:"questions"`
+}
+
+// PageInfo describes the project page a dump was taken from.
+type PageInfo struct {
+	URL         string    `json:"url" yaml:"url"`
+	Title       string    `json:"title" yaml:"title"`
+	ScrapedAt   time.Time `json:"scraped_at" yaml:"scraped_at"`
+	ContentHash string    `json:"content_hash" yaml:"content_hash"`
+	TextFormat  string    `json:"text_format" yaml:"text_format"`
+	ToolVersion string    `json:"tool_version" yaml:"tool_version"`
+}
+
+var (
+	dumpFormat string
+	dumpOutput string
+	dumpHTML   bool
+)
+
+// dumpCmd represents the dump command
+var dumpCmd = &cobra.Command{
+	Use:   "dump [URL | FILE | -]",
+	Short: "Print the scraped questions as JSON or YAML",
+	Long: `Scrapes a project page and prints the question tree together with some
+information about the page, instead of generating a notebook.
+
+The output follows a versioned schema (see the "schema" field) that is
+documented in the README.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if err := cobra.ExactArgs(1)(cmd, args); err != nil {
+			return err
+		}
+		_, err := scrape.Classify(args[0])
+		return err
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if dumpFormat != "json" && dumpFormat != "yaml" {
+			return fmt.Errorf("unknown format %q, expected json or yaml", dumpFormat)
+		}
+		if err := applyConfigFile(cmd); err != nil {
+			return err
+		}
+		project, warnings, err := scrapeProject(cmd.Context(), newScraper(dumpHTML), args[0])
+		if err != nil {
+			return err
+		}
+		textFormat := "markdown"
+		if dumpHTML {
+			textFormat = "html"
+		}
+		dump := Dump{
+			Schema: dumpSchemaVersion,
+			Page: PageInfo{
+				URL:         project.URL,
+				Title:       project.Title,
+				ScrapedAt:   project.ScrapedAt.UTC(),
+				ContentHash: model.Hash(project.Questions),
+				TextFormat:  textFormat,
+				ToolVersion: version,
+			},
+			Questions: project.Questions,
+		}
+		var data []byte
+		if dumpFormat == "json" {
+			data, err = json.MarshalIndent(dump, "", "  ")
+			data = append(data, '\n')
+		} else {
+			data, err = marshalYAML(dump)
+		}
+		if err != nil {
+			return err
+		}
+		if dumpOutput == "" || dumpOutput == "-" {
+			_, err = os.Stdout.Write(data)
+		} else {
+			err = os.WriteFile(dumpOutput, data, 0644)
+		}
+		if err != nil {
+			return err
+		}
+		bestEffort(warnings, "The questions were extracted")
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(dumpCmd)
+	dumpCmd.Flags().StringVarP(&dumpFormat, "format", "f", "json", "output format: json or yaml")
+	dumpCmd.Flags().StringVar(&dumpOutput, "output", "", "file to write to instead of standard output")
+	dumpCmd.Flags().BoolVar(&dumpHTML, "html", false, "keep question text as HTML instead of converting it to Markdown")
+	addScrapeFlags(dumpCmd)
+}
@@ -17,160 +17,141 @@ along with this program. If not, see <http://www.gnu.org/licenses/>.
 package cmd
 
 import (
-	"bufio"
+	"bytes"
 	"fmt"
-	"log"
 	"os"
-	"os/exec"
+	"path/filepath"
 	"strings"
 
-	rom "github.com/brandenc40/romannumeral"
+	"github.com/agarmu/datamine-scraper/model"
+	"github.com/agarmu/datamine-scraper/render"
 )
 
-func toChar(i int) rune {
-	return rune('A' + i)
+// renderOptions collects the settings that go into a notebook.
+func renderOptions() render.Options {
+	return render.Options{
+		Name:                     globalConfig.name,
+		ProjectNumber:            globalConfig.projectNumber,
+		Course:                   globalConfig.course,
+		Term:                     globalConfig.term,
+		SubSubQuestionsOwnBlocks: globalConfig.subsubquestionsOwnCodeBlocks,
+		Kernel:                   globalConfig.kernel,
+		Starters:                 globalConfig.starters,
+		CheckDatasets:            globalConfig.checkDatasets,
+		Images:                   render.ImageMode(globalConfig.images),
+		TAHelp:                   globalConfig.taHelp,
+		Collaborators:            globalConfig.collaborators,
+		Template:                 globalConfig.template,
+		TemplatePath:             globalConfig.templatePath,
+		ToolVersion:              version,
+		ToolCommit:               commit,
+	}
 }
 
-func generateFile() error {
-	// make a tempfile
-	file, err := os.CreateTemp("", "*.md")
-	defer os.Remove(file.Name())
-	if err != nil {
-		fmt.Println("Failed to create temporary file.")
-		log.Fatal(err)
+// loadTemplate reads the skeleton template given with --template or in the
+// configuration file, and checks it for mistakes.
+func loadTemplate() error {
+	if globalConfig.templatePath == "" {
+		return nil
 	}
-	// check for pandoc
-	cmd := exec.Command("pandoc", "--version")
-	err = cmd.Run()
+	data, err := os.ReadFile(globalConfig.templatePath)
 	if err != nil {
-		fmt.Println("Unable to execute pandoc")
-		log.Fatal(err)
+		return err
 	}
-	w := bufio.NewWriter(file)
-	fmt.Fprintln(w, `---
-title: My notebook
-jupyter:
-nbformat: 4
-nbformat_minor: 5
----`)
-	// title
-	fmt.Fprintf(w, `:::::: {.cell .markdown}
-# Project %d -- %s
-
-_This skeleton for this file was generated by [the TDM Scraper made by Mukul Agarwal](https://github.com/agarmu/datamine-scraper) from the contents of [this url](%s)._
-::::::
-:::::: {.cell .markdown}
-**TA Help:** John Smith, Alice Jones
-
-- Help with figuring out how to write a function.
-    
-**Collaboration:** Friend1, Friend2
-
-- Helped figuring out how to load the dataset.
-- Helped debug error with my plot.
-::::::
-
-:::::: {.cell .code}		
-::::::
-`, globalConfig.projectNumber, globalConfig.name, globalConfig.url.String())
-
-	// generate questions
-	for _, q := range questions {
-		fmt.Fprintf(w, `
-:::::: {.cell .markdown}
-## %s
-`, q.Header)
-		if q.Desc != "" {
-			fmt.Fprintf(w, `
-
-**%s**
-`, q.Desc)
-		}
-		fmt.Fprintf(w, "::::::")
-		fmt.Fprintln(w, "")
-		// handle case of 0 subquestions
-		if len(q.Subquestions) == 0 {
-			fmt.Fprint(w, `
-:::::: {.cell .code}		
-
-::::::
+	if _, err := render.ParseTemplate(string(data)); err != nil {
+		return fmt.Errorf("%s: %w", globalConfig.templatePath, err)
+	}
+	globalConfig.template = string(data)
+	return nil
+}
 
-:::::: {.cell .markdown}
-Markdown notes and sentences and analysis written here.
-::::::
+// formatNames lists the registered formats for help and error messages.
+func formatNames() string {
+	names := []string{}
+	for _, f := range render.Formats() {
+		names = append(names, f.Name)
+	}
+	return strings.Join(names, ", ")
+}
 
-`)
+// outputFormat picks the format from --format, then from the extension of
+// the output path, and falls back to a Jupyter notebook.
+func outputFormat(path string) (render.Format, error) {
+	if globalConfig.format != "" {
+		f, ok := render.Lookup(globalConfig.format)
+		if !ok {
+			return f, fmt.Errorf("unknown format %q, expected one of %s", globalConfig.format, formatNames())
 		}
-		for i, sq := range q.Subquestions {
-			fmt.Fprintf(w, `
-:::::: {.cell .markdown}
-**%c. %s**
-`, toChar(i), sq.Header)
-			if !globalConfig.subsubquestionsOwnCodeBlocks {
-				fmt.Fprintf(w, "\n\n")
-				for j, ssq := range sq.Subsubquestions {
-					roman, err := rom.IntToString(j + 1)
-					roman = strings.ToLower(roman)
-					if err != nil {
-						fmt.Printf("Failed to convert %d to roman.\n", j)
-						log.Fatal(err)
-					}
-					fmt.Fprintf(w, `*%s. %s*<br/>`, roman, ssq)
-				}
-				fmt.Fprint(w, `
-::::::
-
-:::::: {.cell .code}		
-
-::::::
-
-:::::: {.cell .markdown}
-Markdown notes and sentences and analysis written here.
-::::::
-
-`)
-			} else {
-				fmt.Fprintln(w, "::::::")
-				for j, ssq := range sq.Subsubquestions {
-					roman, err := rom.IntToString(j + 1)
-					roman = strings.ToLower(roman)
-					if err != nil {
-						fmt.Printf("Failed to convert %d to roman.\n", j)
-						log.Fatal(err)
-					}
-					fmt.Fprintf(w, `:::::: {.cell .markdown}
-*%s. %s*
-::::::
-
-:::::: {.cell .code}		
+		return f, nil
+	}
+	if f, ok := render.ForPath(path); ok {
+		return f, nil
+	}
+	f, _ := render.Lookup("ipynb")
+	return f, nil
+}
 
-::::::
+func renderer(format render.Format) (render.Renderer, error) {
+	if !globalConfig.usePandoc {
+		return format.Renderer, nil
+	}
+	if format.Name != "ipynb" {
+		return nil, fmt.Errorf("--pandoc can only be used for ipynb output, not %s", format.Name)
+	}
+	return render.Pandoc{}, nil
+}
 
-:::::: {.cell .markdown}
-Markdown notes and sentences and analysis written here.
-::::::
-`, roman, ssq)
-				}
-			}
+// imageMode checks --images against the output format: only notebooks can
+// carry attachments.
+func imageMode(format render.Format) (render.ImageMode, error) {
+	mode := render.ImageMode(globalConfig.images)
+	switch mode {
+	case render.ImagesSkip, render.ImagesLink:
+		return mode, nil
+	case render.ImagesEmbed:
+		if format.Name != "ipynb" || globalConfig.usePandoc {
+			return mode, fmt.Errorf("--images embed only works for notebooks written without --pandoc, use --images link for %s", format.Name)
 		}
+		return mode, nil
 	}
-	fmt.Fprint(w, `:::::: {.cell .markdown}
-## Pledge
+	return mode, fmt.Errorf("unknown image mode %q, expected embed, link or skip", globalConfig.images)
+}
 
-By submitting this work I hereby pledge that this is my own, personal work. I've acknowledged in the designated place at the top of this file all sources that I used to complete said work, including but not limited to: online resources, books, and electronic communications. I've noted all collaboration with fellow students and/or TA's. I did not copy or plagiarize another's work.
+// imageDir is the directory next to the output that --images link saves
+// images into, named like the one nbconvert uses.
+func imageDir(path string) string {
+	base := filepath.Base(path)
+	return strings.TrimSuffix(base, filepath.Ext(base)) + "_files"
+}
 
-> As a Boilermaker pursuing academic excellence, I pledge to be honest and true in all that I do. Accountable together – We are Purdue.
-::::::
-`)
-	err = w.Flush()
+// writeProject renders the skeleton of a project in the format its path
+// calls for, in full before writing it, so that a failure never leaves a
+// half-written file behind. Linked images are saved next to it.
+func writeProject(project *model.Project, path string, opts render.Options) error {
+	format, err := outputFormat(path)
 	if err != nil {
-		fmt.Println("Unable to flush writer.")
-		log.Fatal(err)
+		return err
 	}
-	cmd = exec.Command("pandoc", file.Name(), "--to", "ipynb", "--output", globalConfig.path)
-	err = cmd.Run()
+	r, err := renderer(format)
 	if err != nil {
 		return err
 	}
-	return nil
+	if opts.Images == render.ImagesLink && len(project.Images) > 0 {
+		opts.ImageDir = imageDir(path)
+		dir := filepath.Join(filepath.Dir(path), opts.ImageDir)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		for _, image := range project.Images {
+			if err := os.WriteFile(filepath.Join(dir, image.Name), image.Data, 0644); err != nil {
+				return err
+			}
+		}
+	}
+	opts.Format = format.Name
+	var buf bytes.Buffer
+	if err := r.Render(&buf, project, opts); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
 }
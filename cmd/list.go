@@ -0,0 +1,104 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/agarmu/datamine-scraper/scrape"
+	"github.com/spf13/cobra"
+)
+
+var (
+	catalogIndex  string
+	catalogCourse string
+	catalogTerm   string
+	listFormat    string
+)
+
+// listCmd represents the list command
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the projects on the Examples Book",
+	Long: `Crawls the project index pages of the Examples Book and lists every project
+page found on them, with its course, term and number.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyConfigFile(cmd); err != nil {
+			return err
+		}
+		if listFormat != "table" && listFormat != "json" {
+			return fmt.Errorf("unknown format %q, expected table or json", listFormat)
+		}
+		entries, err := catalog(cmd.Context(), newScraper(false))
+		if err != nil {
+			return err
+		}
+		if listFormat == "json" {
+			data, err := json.MarshalIndent(entries, "", "  ")
+			if err != nil {
+				return err
+			}
+			_, err = os.Stdout.Write(append(data, '\n'))
+			return err
+		}
+		if len(entries) == 0 {
+			fmt.Println("No projects found.")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "COURSE\tTERM\tPROJECT\tTITLE\tURL")
+		for _, e := range entries {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", e.Course, e.Term, e.Number, e.Title, e.URL)
+		}
+		return w.Flush()
+	},
+}
+
+// catalog lists the projects on the index pages, keeping those of the
+// course and term asked for.
+func catalog(ctx context.Context, scraper *scrape.Scraper) ([]scrape.CatalogEntry, error) {
+	all, err := scraper.Catalog(ctx, catalogIndex)
+	if err != nil {
+		return nil, err
+	}
+	entries := []scrape.CatalogEntry{}
+	for _, e := range all {
+		if (catalogCourse == "" || e.Course == catalogCourse) && (catalogTerm == "" || e.Term == catalogTerm) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+// addCatalogFlags registers the flags selecting projects from the index.
+func addCatalogFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&catalogIndex, "index", scrape.DefaultIndexURL, "url of the index page to start from")
+	cmd.Flags().StringVar(&catalogTerm, "term", "", "only projects of this term, e.g. 2023")
+}
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+	listCmd.Flags().StringVar(&catalogCourse, "course", "", "only projects of this course, e.g. 10100")
+	listCmd.Flags().StringVarP(&listFormat, "format", "f", "table", "output format: table or json")
+	addCrawlFlags(listCmd)
+	addCatalogFlags(listCmd)
+}
@@ -0,0 +1,70 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/agarmu/datamine-scraper/scrape"
+)
+
+func TestCatalogFilter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/projects/" {
+			http.NotFound(w, r)
+			return
+		}
+		for _, slug := range []string{"10100-2023-project01", "10100-2022-project01", "20100-2023-project01", "20100-2023-project02"} {
+			fmt.Fprintf(w, "<a href=%q>%s</a>\n", slug, slug)
+		}
+	}))
+	defer server.Close()
+	defer func(index, course, term string) {
+		catalogIndex, catalogCourse, catalogTerm = index, course, term
+	}(catalogIndex, catalogCourse, catalogTerm)
+	catalogIndex = server.URL + "/projects/"
+	tests := []struct {
+		course, term string
+		want         []string
+	}{
+		{"", "", []string{"10100-2022-project01", "10100-2023-project01", "20100-2023-project01", "20100-2023-project02"}},
+		{"10100", "", []string{"10100-2022-project01", "10100-2023-project01"}},
+		{"", "2023", []string{"10100-2023-project01", "20100-2023-project01", "20100-2023-project02"}},
+		{"20100", "2023", []string{"20100-2023-project01", "20100-2023-project02"}},
+		{"20100", "2022", []string{}},
+	}
+	scraper := scrape.New(scrape.Options{NoCache: true})
+	for _, tt := range tests {
+		catalogCourse, catalogTerm = tt.course, tt.term
+		entries, err := catalog(context.Background(), scraper)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := []string{}
+		for _, e := range entries {
+			got = append(got, e.Slug())
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("course %q, term %q: got %q, want %q", tt.course, tt.term, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+// newCmd represents the new command
+var newCmd = &cobra.Command{
+	Use:   "new COURSE NUMBER",
+	Short: "Generate the skeleton of a project found on the Examples Book",
+	Long: `Looks a project up on the index pages of the Examples Book, as 'tdmscrape
+list' does, and generates its skeleton as if its url had been given. Without
+--term, the latest term of the course is used.`,
+	Example: `$ tdmscrape new 10100 3`,
+	Args:    cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		course := args[0]
+		number, err := strconv.Atoi(args[1])
+		if err != nil || number < 1 {
+			return fmt.Errorf("%q is not a project number", args[1])
+		}
+		catalogCourse = course
+		// the crawl of the index honours the configured crawler settings too
+		if err := applyConfigFile(cmd); err != nil {
+			return err
+		}
+		// the project page is fetched with the robots.txt and the rate limit
+		// of the crawl
+		scraper := newScraper(false)
+		entries, err := catalog(cmd.Context(), scraper)
+		if err != nil {
+			return err
+		}
+		// entries are sorted, so the last match is from the latest term
+		url := ""
+		for _, e := range entries {
+			if e.Number == number {
+				url = e.URL
+			}
+		}
+		if url == "" {
+			if catalogTerm != "" {
+				return fmt.Errorf("no project %d of course %s in term %s found on %s", number, course, catalogTerm, catalogIndex)
+			}
+			return fmt.Errorf("no project %d of course %s found on %s", number, course, catalogIndex)
+		}
+		return generate(cmd, scraper, url)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(newCmd)
+	addCatalogFlags(newCmd)
+	addGenerateFlags(newCmd)
+}
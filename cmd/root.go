@@ -17,24 +17,20 @@ along with this program. If not, see <http://www.gnu.org/licenses/>.
 package cmd
 
 import (
+	"context"
 	"fmt"
-	"log"
-	"net/url"
 	"os"
-	"strings"
+	"os/signal"
 
-	md "github.com/JohannesKaufmann/html-to-markdown"
-	"github.com/PuerkitoBio/goquery"
-	"github.com/gocolly/colly"
+	"github.com/agarmu/datamine-scraper/model"
+	"github.com/agarmu/datamine-scraper/render"
+	"github.com/agarmu/datamine-scraper/scrape"
 	"github.com/spf13/cobra"
 )
 
-var existSubSubQuestions = false
-var questions []Question
-
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
-	Use:   "tdmscrape [URL]",
+	Use:   "tdmscrape [URL | FILE | -]",
 	Short: "Scrapes Project information from the Data Mine Website into a Jupyter Notebook",
 	Example: `
 To use this program, simply pass the requisite url as an argument.
@@ -43,193 +39,134 @@ E.g., to import the url "https://the-examples-book.com/projects/current-projects
 
 $ tdmscrape "https://the-examples-book.com/projects/current-projects/10100-2023-project01"
 	
-The appropriate .ipynb file will be created in your current directory.`,
+The appropriate .ipynb file will be created in your current directory. Pass
+--format rmd or --format qmd (or an --output path ending in .Rmd or .qmd) to
+get an R Markdown or Quarto document instead.
+
+A saved copy of a project page can be used instead of a url, either as a path
+or on standard input (pass --base-url if the page has no canonical link):
+
+$ tdmscrape ./10100-2023-project01.html
+$ curl "https://the-examples-book.com/projects/current-projects/10100-2023-project01" | tdmscrape -`,
 	Args: func(cmd *cobra.Command, args []string) error {
 		// Optionally run one of the validators provided by cobra
 		if err := cobra.ExactArgs(1)(cmd, args); err != nil {
 			return fmt.Errorf("Exactly 1 argument needed.")
 		}
-		if _, err := url.ParseRequestURI(args[0]); err != nil {
+		if _, err := scrape.Classify(args[0]); err != nil {
 			return err
 		}
 		return nil
 	},
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		// the arguments and flags have been checked by now, so errors from
+		// here on are no reason to show the usage
+		cmd.SilenceUsage = true
+		startUpdateCheck(cmd)
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		var err error
-		globalConfig.url, err = url.ParseRequestURI(args[0])
-		if err != nil {
-			l := log.New(os.Stderr, "", 0)
-			l.Println("UNREACHABLE: URL DETECTION from Args Validator BYPASSED.")
-			l.Printf("Args: %s", args)
-			l.Printf("Please report this as a bug. (use tdmscrape info to get more info about reporting)")
-			os.Exit(255)
-		}
-		questions, err = scrapeURL()
-		if err != nil {
+		if err := applyConfigFile(cmd); err != nil {
 			return err
 		}
-		// post-processing
-		postProcess()
-		// user interaction
-		err = getInitialUserInput()
-		if err != nil {
-			return err
+		return generate(cmd, newScraper(false), args[0])
+	},
+}
+
+// generate scrapes the project page named by source with scraper and writes
+// its skeleton, asking for whatever the flags, environment and configuration
+// file leave open. The configuration file has been applied already, for the
+// scraper to follow it.
+func generate(cmd *cobra.Command, scraper *scrape.Scraper, source string) error {
+	if err := applyEnvironment(cmd); err != nil {
+		return err
+	}
+	// catch a bad --format before doing any work
+	format, err := outputFormat(globalConfig.path)
+	if err != nil {
+		return err
+	}
+	if _, err := renderer(format); err != nil {
+		return err
+	}
+	images, err := imageMode(format)
+	if err != nil {
+		return err
+	}
+	if err := loadTemplate(); err != nil {
+		return err
+	}
+	project, warnings, err := scrapeProject(cmd.Context(), scraper, source)
+	if err != nil {
+		return err
+	}
+	if globalConfig.listDatasets {
+		for _, path := range model.Datasets(project.Questions) {
+			fmt.Println(path)
 		}
-		err = generateFile()
-		if err != nil {
+		bestEffort(warnings, "The datasets were found")
+		return nil
+	}
+	if images != render.ImagesSkip {
+		if err := fetchImages(cmd.Context(), scraper, project); err != nil {
 			return err
 		}
-		return nil
-	},
+	}
+	identifyProject(project)
+	// user interaction
+	err = getInitialUserInput()
+	if err != nil {
+		return err
+	}
+	if err := writeProject(project, globalConfig.path, renderOptions()); err != nil {
+		return err
+	}
+	bestEffort(warnings, "The notebook was generated")
+	return nil
 }
 
 // This is called by main.main(). It only needs to happen once to the rootCmd.
-func Execute() {
-	err := rootCmd.Execute()
+// release is the version this program was released as, which is checked
+// against the latest release on GitHub.
+func Execute(release string) {
+	releaseVersion = release
+	// stop fetching pages on Ctrl-C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
+	reportUpdateCheck()
 	if err != nil {
 		os.Exit(1)
 	}
 }
 
 func init() {
-	rootCmd.Flags().BoolVarP(&globalConfig.overwrite, "overwrite", "o", false, "Overwrite existing notebook")
-	rootCmd.Flags().BoolVarP(&globalConfig.subsubquestionsOwnCodeBlocks, "sub-sub-questions-own-blocks", "s", false, "sub-sub-questions get their own code blocks and response area")
-	rootCmd.Flags().StringVarP(&globalConfig.name, "name", "n", "", "name to use for document")
-	rootCmd.Flags().IntVarP(&globalConfig.projectNumber, "number", "i", -1, "project number")
-}
-
-func scrapeURL() ([]Question, error) {
-	c := colly.NewCollector()
-	questions := []Question{}
-	// Find and visit all question sections
-	c.OnHTML(".sect2", func(question *colly.HTMLElement) {
-		// inside question area
-		q := Question{
-			Header:       "",
-			Desc:         "",
-			Subquestions: []SubQuestion{},
-		}
-		var err error
-		q.Header = strings.TrimSpace(question.DOM.Find("h3").First().Text())
-		if !strings.Contains(q.Header, "Question") {
-			// not a question, exit
-			return
-		}
-		// get the description, if it exists
-		desc := question.DOM.Find(".paragraph strong")
-		if desc.Length() > 0 {
-			// there is a description, extract it
-			q.Desc, err = desc.First().Html()
-			if err != nil {
-				log.Panic("Malformed Question Description: ", err)
-			}
-			q.Desc = strings.TrimSpace(q.Desc)
-		}
-		// get the subquestions, if they exist
-		// var hasSubquestions = true
-		subquestions := question.DOM.Find(".olist ol")
-		if subquestions.Length() < 1 {
-			subquestions = question.DOM.Find(".ulist ul")
-			if subquestions.Length() < 1 {
-				// number of subquestions is 0!
-				// hasSubquestions = false
-			}
-		}
-		subquestions = subquestions.First().ChildrenFiltered("li")
-		if subquestions.Length() >= 1 {
-			subquestions.Each(func(i int, s *goquery.Selection) {
-				sq := SubQuestion{
-					Header:          "",
-					Subsubquestions: []string{},
-				}
-				headerParagraph := s.ChildrenFiltered("p")
-				if headerParagraph.Length() != 1 {
-					log.Panicln("Wrong number of subquestions in one container.", s.Text())
-				}
-				header, err := headerParagraph.First().Html()
-				if err != nil {
-					log.Panic(err)
-				}
-				sq.Header = strings.TrimSpace(header)
-				// get subquestions, if any
-				subsubquestions := s.Find(".olist ol")
-				if subsubquestions.Length() < 1 {
-					subsubquestions = s.Find(".ulist ul")
-				}
-				if subsubquestions.Length() == 1 {
-					// there are subquestions. great!
-					subsubquestions := subsubquestions.First().ChildrenFiltered("li")
-					subsubquestions.Each(func(_ int, s *goquery.Selection) {
-						ssqParagraph := s.ChildrenFiltered("p")
-						if ssqParagraph.Length() != 1 {
-							log.Panicln("Wrong number of subquestions in one container.", s.Text())
-						}
-						ssq, err := ssqParagraph.First().Html()
-						if err != nil {
-							log.Panic(err)
-						}
-						sq.Subsubquestions = append(sq.Subsubquestions, strings.TrimSpace(ssq))
-					})
-				}
-				q.Subquestions = append(q.Subquestions, sq)
-			})
-		}
-		questions = append(questions, q)
-	})
-	err := c.Visit(globalConfig.url.String())
-	return questions, err
-}
-
-type Question struct {
-	Header       string
-	Desc         string
-	Subquestions []SubQuestion
+	rootCmd.PersistentFlags().StringVar(&globalConfig.profile, "profile", "", "configuration profile to use")
+	rootCmd.PersistentFlags().BoolVar(&noUpdateCheck, "no-update-check", false, "do not check for a newer release of tdmscrape")
+	addGenerateFlags(rootCmd)
 }
 
-type SubQuestion struct {
-	Header          string
-	Subsubquestions []string
+// addGenerateFlags registers the flags controlling how a skeleton is
+// generated, for every command that generates a single one.
+func addGenerateFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVarP(&globalConfig.projectNumber, "number", "i", -1, "project number")
+	cmd.Flags().StringVar(&globalConfig.path, "output", "", "path of the notebook to write")
+	cmd.Flags().BoolVar(&globalConfig.nonInteractive, "non-interactive", false, "never prompt, fail if a required value is missing")
+	cmd.Flags().BoolVar(&globalConfig.listDatasets, "list-datasets", false, "only print the paths of the datasets the project uses")
+	addLayoutFlags(cmd)
 }
 
-func sanitize(question Question, domain string) (Question, error) {
-	converter := md.NewConverter(domain, true, nil)
-	var err error
-	q := question
-	q.Header, err = converter.ConvertString(q.Header)
-	if err != nil {
-		return q, err
-	}
-	for i := range q.Subquestions {
-		q.Subquestions[i].Header, err = converter.ConvertString(q.Subquestions[i].Header)
-		if err != nil {
-			return q, err
-		}
-		for j := range q.Subquestions[i].Subsubquestions {
-			q.Subquestions[i].Subsubquestions[j], err = converter.ConvertString(q.Subquestions[i].Subsubquestions[j])
-			if err != nil {
-				return q, err
-			}
-		}
-	}
-	return q, nil
-}
-
-func postProcess() error {
-	domain := globalConfig.url.Scheme + "://" + globalConfig.url.Hostname()
-	var err error
-	for _, q := range questions {
-		q, err = sanitize(q, domain)
-		if err != nil {
-			return err
-		}
-		if !existSubSubQuestions {
-			for _, q := range q.Subquestions {
-				if len(q.Subsubquestions) > 0 {
-					existSubSubQuestions = true
-					break
-				}
-			}
-		}
-	}
-	return nil
+// addLayoutFlags registers the flags controlling what goes into skeletons,
+// for every command that generates any.
+func addLayoutFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVarP(&globalConfig.overwrite, "overwrite", "o", false, "Overwrite existing notebook")
+	cmd.Flags().BoolVarP(&globalConfig.subsubquestionsOwnCodeBlocks, "sub-sub-questions-own-blocks", "s", false, "sub-sub-questions get their own code blocks and response area")
+	cmd.Flags().StringVarP(&globalConfig.name, "name", "n", "", "name to use for document")
+	cmd.Flags().StringVar(&globalConfig.format, "format", "", "output format: "+formatNames()+" (default from the output file extension, else ipynb)")
+	cmd.Flags().StringVar(&globalConfig.kernel, "kernel", "", "Jupyter kernel to use, e.g. f2023-s2024 or ir (default from the code on the project page)")
+	cmd.Flags().BoolVar(&globalConfig.starters, "starters", false, "pre-fill setup and answer cells with imports, cell magics and the like")
+	cmd.Flags().BoolVar(&globalConfig.checkDatasets, "check-datasets", false, "add a setup cell that checks that the datasets of the project exist")
+	cmd.Flags().StringVar(&globalConfig.images, "images", string(render.ImagesSkip), "embed images in the notebook, link to copies saved next to it, or skip downloading them")
+	cmd.Flags().StringVar(&globalConfig.templatePath, "template", "", "text/template file to lay the notebook out with")
+	cmd.Flags().BoolVar(&globalConfig.usePandoc, "pandoc", false, "use pandoc to build the notebook instead of the native writer")
+	addScrapeFlags(cmd)
 }
@@ -0,0 +1,144 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/agarmu/datamine-scraper/model"
+	"github.com/agarmu/datamine-scraper/scrape"
+	"github.com/spf13/cobra"
+)
+
+// The defaults keep a scrape going on a flaky connection without hanging on
+// a dead one, and space requests out like a person clicking through pages.
+const (
+	defaultTimeout = 30 * time.Second
+	defaultRetries = 3
+	defaultDelay   = time.Second
+)
+
+// addScrapeFlags registers the flags controlling how a page is fetched and
+// parsed, for every command that scrapes one.
+func addScrapeFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&globalConfig.baseURL, "base-url", "", "url of the page when reading from a file or stdin")
+	cmd.Flags().BoolVar(&globalConfig.strict, "strict", false, "treat any unexpected page structure as an error")
+	addCrawlFlags(cmd)
+}
+
+// addCrawlFlags registers the flags controlling how pages are fetched, for
+// every command that fetches any.
+func addCrawlFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&globalConfig.offline, "offline", false, "only use pages from the cache, never the network")
+	cmd.Flags().BoolVar(&globalConfig.noCache, "no-cache", false, "neither read nor write the page cache")
+	cmd.MarkFlagsMutuallyExclusive("offline", "no-cache")
+	cmd.Flags().DurationVar(&globalConfig.timeout, "timeout", defaultTimeout, "give up on a request after this long (0 for never)")
+	cmd.Flags().IntVar(&globalConfig.retries, "retries", defaultRetries, "retry requests failing with a network or server error this many times")
+	cmd.Flags().DurationVar(&globalConfig.delay, "delay", defaultDelay, "wait this long between requests to the same site")
+	cmd.Flags().StringVar(&globalConfig.userAgent, "user-agent", "", "User-Agent to send (default tdmscrape/VERSION and the repository url)")
+	cmd.Flags().BoolVar(&globalConfig.ignoreRobots, "ignore-robots", false, "fetch pages even where robots.txt disallows it")
+}
+
+// userAgent returns the configured User-Agent, which defaults to one naming
+// this version of tdmscrape.
+func userAgent() string {
+	if globalConfig.userAgent != "" {
+		return globalConfig.userAgent
+	}
+	return fmt.Sprintf("tdmscrape/%s (+https://github.com/agarmu/datamine-scraper)", version)
+}
+
+// scrapeOptions are the configured options for fetching and parsing pages.
+func scrapeOptions(keepHTML bool) scrape.Options {
+	return scrape.Options{
+		BaseURL:      globalConfig.baseURL,
+		Offline:      globalConfig.offline,
+		NoCache:      globalConfig.noCache,
+		Strict:       globalConfig.strict,
+		KeepHTML:     keepHTML,
+		RewriteRules: globalConfig.rewriteRules,
+		Timeout:      globalConfig.timeout,
+		Retries:      globalConfig.retries,
+		Delay:        globalConfig.delay,
+		UserAgent:    userAgent(),
+		IgnoreRobots: globalConfig.ignoreRobots,
+	}
+}
+
+// newScraper returns a scraper with the configured options, which prints
+// the problems it runs into as warnings.
+func newScraper(keepHTML bool) *scrape.Scraper {
+	opts := scrapeOptions(keepHTML)
+	opts.Warn = func(err error) {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", err)
+	}
+	return scrape.New(opts)
+}
+
+// scrapeProject scrapes the page named by source, printing every problem
+// found on it as it goes. The problems are returned too, for the caller to
+// mention once its output is written. In strict mode any problem is fatal.
+func scrapeProject(ctx context.Context, scraper *scrape.Scraper, source string) (*model.Project, []*scrape.ParseError, error) {
+	var warnings []*scrape.ParseError
+	scraper = scraper.WithWarn(func(err error) {
+		var e *scrape.ParseError
+		if errors.As(err, &e) {
+			warnings = append(warnings, e)
+		}
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", err)
+	})
+	project, err := scraper.Scrape(ctx, source)
+	var problems scrape.ParseErrors
+	if errors.As(err, &problems) {
+		for _, e := range problems {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", e)
+		}
+		return nil, nil, fmt.Errorf("%w (--strict)", err)
+	} else if err != nil {
+		return nil, nil, err
+	}
+	return project, warnings, nil
+}
+
+// bestEffort reminds that what was made from a page with problems on it,
+// e.g. "The notebook was generated", needs checking.
+func bestEffort(warnings []*scrape.ParseError, what string) {
+	if len(warnings) > 0 {
+		fmt.Fprintf(os.Stderr, "%s on a best-effort basis; please check the questions above against the project page.\n", what)
+	}
+}
+
+// fetchImages downloads the images of a project with the scraper the page
+// came from, warning about those that could not be downloaded.
+func fetchImages(ctx context.Context, scraper *scrape.Scraper, project *model.Project) error {
+	err := scraper.FetchImages(ctx, project)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	if err != nil {
+		for _, line := range strings.Split(err.Error(), "\n") {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", line)
+		}
+		fmt.Fprintln(os.Stderr, "The images above were left as links to the website.")
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/agarmu/datamine-scraper/internal/fileutil"
+	"github.com/agarmu/datamine-scraper/model"
+	"github.com/agarmu/datamine-scraper/notebook"
+	"github.com/agarmu/datamine-scraper/render"
+	"github.com/spf13/cobra"
+)
+
+var (
+	updateSource string
+	updateDryRun bool
+)
+
+// updateCmd represents the update command
+var updateCmd = &cobra.Command{
+	Use:   "update NOTEBOOK",
+	Short: "Bring an existing notebook up to date with its project page, keeping your answers",
+	Long: `Re-scrapes the project page a notebook was generated from and merges the
+result into the notebook. All of your code and answer cells are kept. Prompts
+that are new on the page are inserted, and prompts that were reworded or
+removed are flagged with a note in the notebook.
+
+A backup of the original notebook is written next to it with a .bak suffix,
+followed by a number if earlier backups are in the way.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyConfigFile(cmd); err != nil {
+			return err
+		}
+		if err := loadTemplate(); err != nil {
+			return err
+		}
+		path := args[0]
+		old, err := notebook.Read(path)
+		if err != nil {
+			return err
+		}
+		source := updateSource
+		if source == "" {
+			source = old.SourceURL()
+		}
+		if source == "" {
+			return errors.New("could not find the project url in the notebook, pass it with --source")
+		}
+		project, warnings, err := scrapeProject(cmd.Context(), newScraper(false), source)
+		if err != nil {
+			return err
+		}
+		oldLayout := notebook.Split(old.Cells)
+		// keep the layout the notebook was generated with unless told otherwise
+		if !cmd.Flags().Changed("sub-sub-questions-own-blocks") {
+			if old.Metadata.Tdmscrape != nil {
+				globalConfig.subsubquestionsOwnCodeBlocks = old.Metadata.Tdmscrape.Options.SubSubQuestionsOwnBlocks
+			} else {
+				globalConfig.subsubquestionsOwnCodeBlocks = oldLayout.HasSubSubPrompts()
+			}
+		}
+		opts := renderOptions()
+		opts.Format = "ipynb"
+		// new answer cells are for the kernel the notebook already uses
+		if ks := old.Metadata.Kernelspec; ks != nil && !cmd.Flags().Changed("kernel") {
+			opts.Kernel = ks.Name
+			opts.Language = model.LanguagePython
+			if strings.EqualFold(ks.Language, notebook.RKernelspec.Language) {
+				opts.Language = model.LanguageR
+			}
+		}
+		fresh, err := render.Cells(project, opts)
+		if err != nil {
+			return err
+		}
+		merged, report := notebook.Merge(oldLayout, notebook.Split(fresh))
+		printReport(report)
+		if updateDryRun {
+			bestEffort(warnings, "The changes were worked out")
+			return nil
+		}
+		nb := old
+		nb.Cells = merged
+		if nb.NbformatMinor < 5 {
+			// every cell now has an id
+			nb.NbformatMinor = 5
+		}
+		nb.Metadata.Tdmscrape = render.Provenance(project, opts, "native")
+		if cmd.Flags().Changed("kernel") {
+			kernelspec := render.Kernelspec(project, opts)
+			nb.Metadata.Kernelspec = &kernelspec
+			nb.Metadata.LanguageInfo = &notebook.LanguageInfo{Name: kernelspec.Language}
+		}
+		backup, err := fileutil.Backup(path)
+		if err != nil {
+			return fmt.Errorf("could not back up notebook: %w", err)
+		}
+		data, err := nb.Encode()
+		if err != nil {
+			return err
+		}
+		if err := fileutil.WriteAtomic(path, data); err != nil {
+			return err
+		}
+		fmt.Println("The original notebook was backed up to", backup)
+		bestEffort(warnings, "The notebook was updated")
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(updateCmd)
+	updateCmd.Flags().StringVar(&updateSource, "source", "", "url, file or - to scrape instead of the one recorded in the notebook")
+	updateCmd.Flags().BoolVar(&updateDryRun, "dry-run", false, "only report what would change")
+	updateCmd.Flags().StringVar(&globalConfig.kernel, "kernel", "", "switch the notebook to this Jupyter kernel")
+	updateCmd.Flags().BoolVar(&globalConfig.starters, "starters", false, "pre-fill the answer cells of new prompts")
+	updateCmd.Flags().StringVar(&globalConfig.templatePath, "template", "", "text/template file the notebook was laid out with")
+	updateCmd.Flags().BoolVarP(&globalConfig.subsubquestionsOwnCodeBlocks, "sub-sub-questions-own-blocks", "s", false, "sub-sub-questions get their own code blocks and response area")
+	addScrapeFlags(updateCmd)
+}
+
+func printReport(r notebook.Report) {
+	if r.Empty() {
+		fmt.Println("The notebook is already up to date.")
+		return
+	}
+	for _, k := range r.Added {
+		fmt.Println("Added:   ", k)
+	}
+	for _, k := range r.Reworded {
+		fmt.Println("Reworded:", k)
+	}
+	for _, k := range r.Removed {
+		fmt.Println("Removed: ", k)
+	}
+}
@@ -0,0 +1,217 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	goversion "github.com/hashicorp/go-version"
+	"github.com/spf13/cobra"
+	latest "github.com/tcnksm/go-latest"
+)
+
+const (
+	envNoUpdateCheck = "TDMSCRAPE_NO_UPDATE_CHECK"
+	// updateCheckTimeout bounds how long GitHub is asked for the latest
+	// release; a slow answer counts as no answer.
+	updateCheckTimeout = 3 * time.Second
+	// updateCheckGrace is how long a command that is done waits for a check
+	// that is not.
+	updateCheckGrace = 500 * time.Millisecond
+	// updateCheckInterval is how long the answer is remembered.
+	updateCheckInterval = 24 * time.Hour
+)
+
+var (
+	// releaseVersion is the version this program was released as.
+	releaseVersion string
+	// noUpdateCheck is set by --no-update-check.
+	noUpdateCheck bool
+	// newerRelease receives the latest release once a background check
+	// finds one newer than this, or "" otherwise. It is nil when no check
+	// was started.
+	newerRelease chan string
+	// githubAPI is where the releases are looked up.
+	githubAPI = "https://api.github.com"
+)
+
+// updateCheckCache is the outcome of the last check, kept in the user cache
+// directory.
+type updateCheckCache struct {
+	Checked  time.Time `json:"checked"`
+	Version  string    `json:"version"` // the version that was checked
+	Latest   string    `json:"latest"`
+	Outdated bool      `json:"outdated"`
+}
+
+// startUpdateCheck looks for a newer release, unless disabled by flag,
+// environment or configuration file. A recent answer is taken from the
+// cache; otherwise GitHub is asked in the background.
+func startUpdateCheck(cmd *cobra.Command) {
+	if !updateCheckEnabled(cmd) {
+		return
+	}
+	newerRelease = make(chan string, 1)
+	if cache, ok := cachedUpdateCheck(releaseVersion); ok {
+		newerRelease <- outdatedRelease(cache)
+		return
+	}
+	go func() {
+		newerRelease <- checkForUpdate(releaseVersion)
+	}()
+}
+
+func updateCheckEnabled(cmd *cobra.Command) bool {
+	if releaseVersion == "" || noUpdateCheck {
+		return false
+	}
+	// shell completion is read by the shell, not by the user
+	if cmd.Hidden || cmd.Name() == "completion" || (cmd.HasParent() && cmd.Parent().Name() == "completion") {
+		return false
+	}
+	if v, ok := os.LookupEnv(envNoUpdateCheck); ok {
+		if off, err := strconv.ParseBool(v); err != nil || off {
+			return false
+		}
+	}
+	// a broken configuration file is reported by the command itself
+	if cf, err := loadConfigFile(); err == nil {
+		if s, err := cf.settings(globalConfig.profile); err == nil && s.UpdateCheck != nil {
+			return *s.UpdateCheck
+		}
+	}
+	return true
+}
+
+// cachedUpdateCheck returns the outcome of the last check, if it was for
+// current and is recent enough.
+func cachedUpdateCheck(current string) (updateCheckCache, bool) {
+	var cache updateCheckCache
+	path, err := updateCheckPath()
+	if err != nil {
+		return cache, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || json.Unmarshal(data, &cache) != nil {
+		return cache, false
+	}
+	return cache, cache.Version == current && time.Since(cache.Checked) < updateCheckInterval
+}
+
+// checkForUpdate asks GitHub for the latest release and returns it if it is
+// newer than current, caching the answer. Any failure means "" rather than
+// an error: the check must never get in the way, least of all when GitHub
+// cannot be reached.
+func checkForUpdate(current string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), updateCheckTimeout)
+	defer cancel()
+	res, err := latest.Check(&githubTags{ctx: ctx, api: githubAPI, repository: "agarmu/datamine-scraper"}, current)
+	if err != nil {
+		return ""
+	}
+	cache := updateCheckCache{Checked: time.Now(), Version: current, Latest: res.Current, Outdated: res.Outdated}
+	if path, err := updateCheckPath(); err == nil {
+		if data, err := json.Marshal(cache); err == nil && os.MkdirAll(filepath.Dir(path), 0755) == nil {
+			os.WriteFile(path, data, 0644)
+		}
+	}
+	return outdatedRelease(cache)
+}
+
+// githubTags is a go-latest source for the tags of a GitHub repository,
+// like latest.GithubTag but with a request bound to ctx.
+type githubTags struct {
+	ctx        context.Context
+	api        string // e.g. "https://api.github.com"
+	repository string // e.g. "agarmu/datamine-scraper"
+}
+
+func (g *githubTags) Validate() error {
+	return nil
+}
+
+func (g *githubTags) Fetch() (*latest.FetchResponse, error) {
+	req, err := http.NewRequestWithContext(g.ctx, http.MethodGet, g.api+"/repos/"+g.repository+"/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	client := &http.Client{Timeout: updateCheckTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	var tags []struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return nil, err
+	}
+	fr := &latest.FetchResponse{Meta: &latest.Meta{}}
+	for _, tag := range tags {
+		v, err := goversion.NewVersion(strings.TrimPrefix(tag.Name, "v"))
+		if err != nil {
+			fr.Malformeds = append(fr.Malformeds, tag.Name)
+			continue
+		}
+		fr.Versions = append(fr.Versions, v)
+	}
+	return fr, nil
+}
+
+func outdatedRelease(cache updateCheckCache) string {
+	if cache.Outdated {
+		return cache.Latest
+	}
+	return ""
+}
+
+func updateCheckPath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "tdmscrape", "update-check.json"), nil
+}
+
+// reportUpdateCheck warns about a newer release if the background check
+// finds one by now, or within updateCheckGrace. A check that takes longer
+// is given up on; the next run asks again.
+func reportUpdateCheck() {
+	if newerRelease == nil {
+		return
+	}
+	select {
+	case newer := <-newerRelease:
+		if newer != "" {
+			fmt.Fprintf(os.Stderr, "Warning: tdmscrape %s is available, you have %s. Download it from https://github.com/agarmu/datamine-scraper/releases\n", newer, releaseVersion)
+		}
+	case <-time.After(updateCheckGrace):
+	}
+}
@@ -0,0 +1,128 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// testGithub serves tags as the tags of the repository, and restores the
+// update check's state after the test.
+func testGithub(t *testing.T, tags string) {
+	t.Helper()
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/agarmu/datamine-scraper/tags" || tags == "" {
+			http.Error(w, "rate limited", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(tags))
+	}))
+	t.Cleanup(server.Close)
+	saved := githubAPI
+	githubAPI = server.URL
+	t.Cleanup(func() { githubAPI = saved })
+}
+
+func TestCheckForUpdate(t *testing.T) {
+	tags := `[{"name": "nightly"}, {"name": "v1.10.0"}, {"name": "v1.9.2"}, {"name": "1.2.0"}]`
+	tests := []struct {
+		current, want string
+	}{
+		{"1.2.0", "1.10.0"},
+		{"1.10.0", ""},
+		{"2.0.0", ""},
+	}
+	for _, tt := range tests {
+		testGithub(t, tags)
+		if got := checkForUpdate(tt.current); got != tt.want {
+			t.Errorf("checkForUpdate(%q) = %q, want %q", tt.current, got, tt.want)
+		}
+		cache, ok := cachedUpdateCheck(tt.current)
+		if !ok || outdatedRelease(cache) != tt.want {
+			t.Errorf("%s: got cached %+v, %t", tt.current, cache, ok)
+		}
+		// an answer for another version does not count
+		if _, ok := cachedUpdateCheck(tt.current + "-dev"); ok {
+			t.Errorf("%s: took the cached answer for another version", tt.current)
+		}
+	}
+}
+
+// Failing to ask GitHub is no error, and is not remembered.
+func TestCheckForUpdateFails(t *testing.T) {
+	testGithub(t, "")
+	if got := checkForUpdate("1.2.0"); got != "" {
+		t.Errorf("got %q", got)
+	}
+	if _, ok := cachedUpdateCheck("1.2.0"); ok {
+		t.Error("cached a failed check")
+	}
+}
+
+func TestUpdateCheckEnabled(t *testing.T) {
+	defer func(release string, off bool) {
+		releaseVersion, noUpdateCheck = release, off
+	}(releaseVersion, noUpdateCheck)
+	tests := []struct {
+		name    string
+		release string
+		flag    bool
+		env     string
+		config  string
+		want    bool
+	}{
+		{name: "enabled", release: "1.2.0", want: true},
+		{name: "no release", release: "", want: false},
+		{name: "flag", release: "1.2.0", flag: true, want: false},
+		{name: "environment", release: "1.2.0", env: "1", want: false},
+		{name: "environment off", release: "1.2.0", env: "false", want: true},
+		{name: "configuration file", release: "1.2.0", config: "update_check: false\n", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			t.Setenv("XDG_CONFIG_HOME", dir)
+			// unset unless the test sets it, and restored after it
+			t.Setenv(envNoUpdateCheck, tt.env)
+			if tt.env == "" {
+				os.Unsetenv(envNoUpdateCheck)
+			}
+			if tt.config != "" {
+				path := filepath.Join(dir, "tdmscrape", "config.yaml")
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(tt.config), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			releaseVersion, noUpdateCheck = tt.release, tt.flag
+			if got := updateCheckEnabled(&cobra.Command{Use: "tdmscrape"}); got != tt.want {
+				t.Errorf("got %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
@@ -21,39 +21,255 @@ import (
 	"fmt"
 	"io/fs"
 	"log"
-	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/agarmu/datamine-scraper/model"
+	"github.com/agarmu/datamine-scraper/render"
+	"github.com/agarmu/datamine-scraper/scrape"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mattn/go-isatty"
+	"github.com/spf13/cobra"
 )
 
 type Config struct {
 	subsubquestionsOwnCodeBlocks bool
 	name                         string
 	projectNumber                int
+	course                       string
+	term                         string
 	overwrite                    bool
 	path                         string
-	url                          *url.URL
+	baseURL                      string
+	usePandoc                    bool
+	format                       string
+	templatePath                 string
+	images                       string
+	template                     string
+	offline                      bool
+	noCache                      bool
+	nonInteractive               bool
+	profile                      string
+	outputDir                    string
+	filenamePattern              string
+	kernel                       string
+	starters                     bool
+	checkDatasets                bool
+	listDatasets                 bool
+	taHelp                       []string
+	collaborators                []string
+	strict                       bool
+	rewriteRules                 []scrape.RewriteRule
+	timeout                      time.Duration
+	retries                      int
+	delay                        time.Duration
+	userAgent                    string
+	ignoreRobots                 bool
 }
 
 var globalConfig = Config{
 	subsubquestionsOwnCodeBlocks: false,
 	name:                         "",
 	projectNumber:                -1,
+	course:                       "",
+	term:                         "",
 	overwrite:                    false,
 	path:                         "",
-	url:                          nil,
+	baseURL:                      "",
+	usePandoc:                    false,
+	format:                       "",
+	templatePath:                 "",
+	images:                       string(render.ImagesSkip),
+	template:                     "",
+	offline:                      false,
+	noCache:                      false,
+	nonInteractive:               false,
+	profile:                      "",
+	outputDir:                    "",
+	filenamePattern:              defaultFilenamePattern,
+	kernel:                       "",
+	starters:                     false,
+	checkDatasets:                false,
+	listDatasets:                 false,
+	taHelp:                       nil,
+	collaborators:                nil,
+	strict:                       false,
+	rewriteRules:                 nil,
+	timeout:                      defaultTimeout,
+	retries:                      defaultRetries,
+	delay:                        defaultDelay,
+	userAgent:                    "",
+	ignoreRobots:                 false,
 }
 
 func isSet() bool {
 	return globalConfig.projectNumber > 0 && globalConfig.name != ""
 }
 
+// Environment variables consulted for values not given as flags.
+const (
+	envName           = "TDMSCRAPE_NAME"
+	envNumber         = "TDMSCRAPE_NUMBER"
+	envOutput         = "TDMSCRAPE_OUTPUT"
+	envNonInteractive = "TDMSCRAPE_NON_INTERACTIVE"
+)
+
+// applyEnvironment fills in values from the environment for every flag that
+// was not given explicitly on the command line.
+func applyEnvironment(cmd *cobra.Command) error {
+	flags := cmd.Flags()
+	if v, ok := os.LookupEnv(envName); ok && !flags.Changed("name") {
+		globalConfig.name = strings.TrimSpace(v)
+	}
+	if v, ok := os.LookupEnv(envNumber); ok && !flags.Changed("number") {
+		number, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("%s is not a number: %q", envNumber, v)
+		}
+		globalConfig.projectNumber = number
+	}
+	if v, ok := os.LookupEnv(envOutput); ok && !flags.Changed("output") {
+		globalConfig.path = strings.TrimSpace(v)
+	}
+	if v, ok := os.LookupEnv(envNonInteractive); ok && !flags.Changed("non-interactive") {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("%s is not a boolean: %q", envNonInteractive, v)
+		}
+		globalConfig.nonInteractive = b
+	}
+	return nil
+}
+
+// isInteractive reports whether prompts may be shown. Besides the explicit
+// --non-interactive flag, prompting is skipped under CI and whenever there is
+// no terminal to draw on.
+func isInteractive() bool {
+	if globalConfig.nonInteractive {
+		return false
+	}
+	if ci, _ := strconv.ParseBool(os.Getenv("CI")); ci {
+		return false
+	}
+	if !isatty.IsTerminal(os.Stdout.Fd()) && !isatty.IsCygwinTerminal(os.Stdout.Fd()) {
+		return false
+	}
+	if isatty.IsTerminal(os.Stdin.Fd()) || isatty.IsCygwinTerminal(os.Stdin.Fd()) {
+		return true
+	}
+	// input was piped in, so prompts need a terminal of their own
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return false
+	}
+	tty.Close()
+	return true
+}
+
+// defaultFilenamePattern names notebooks like "first-last-project01.ipynb".
+// {name} is the dash-connected lower-case name, {number} the zero-padded
+// project number, {course} and {term} those of the project (empty if they
+// are not known) and {ext} the extension of the output format.
+const defaultFilenamePattern = "{name}-project{number}{ext}"
+
+func defaultOutputPath() (string, error) {
+	dir := globalConfig.outputDir
+	if dir == "" {
+		var err error
+		dir, err = os.Getwd()
+		if err != nil {
+			return "", err
+		}
+	}
+	filename, err := outputFilename(globalConfig.projectNumber, globalConfig.course, globalConfig.term)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filename), nil
+}
+
+// outputFilename names the notebook of a project after the filename pattern.
+func outputFilename(number int, course string, term string) (string, error) {
+	format, err := outputFormat("")
+	if err != nil {
+		return "", err
+	}
+	dashConnectedName := strings.Join(strings.Split(strings.ToLower(globalConfig.name), " "), "-")
+	return strings.NewReplacer(
+		"{name}", dashConnectedName,
+		"{number}", fmt.Sprintf("%02d", number),
+		"{course}", course,
+		"{term}", term,
+		"{ext}", format.Extension,
+	).Replace(globalConfig.filenamePattern), nil
+}
+
+// identifyProject fills in the project number, unless it was given, and the
+// course and term from the url and title of the project page.
+func identifyProject(project *model.Project) {
+	id := project.Identify()
+	if globalConfig.projectNumber <= 0 && id.Number > 0 {
+		globalConfig.projectNumber = id.Number
+	}
+	globalConfig.course = id.Course
+	globalConfig.term = id.Term
+}
+
+var errPathExists = errors.New("that path already exists")
+
+// checkOutputPath makes path absolute and makes sure that writing to it will
+// not clobber an existing file unless --overwrite was given.
+func checkOutputPath(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	_, err = os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && globalConfig.overwrite) {
+		return path, nil
+	} else if err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("%s: %w (use --overwrite to replace it)", path, errPathExists)
+}
+
+// resolveNonInteractive fills in the output path without prompting, and
+// reports every value that is still missing in a single error.
+func resolveNonInteractive() error {
+	missing := []string{}
+	if globalConfig.name == "" {
+		missing = append(missing, fmt.Sprintf("name (--name or %s)", envName))
+	}
+	if globalConfig.projectNumber <= 0 {
+		missing = append(missing, fmt.Sprintf("project number (--number or %s)", envNumber))
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("running non-interactively, but missing: %s", strings.Join(missing, ", "))
+	}
+	path := globalConfig.path
+	if path == "" {
+		var err error
+		path, err = defaultOutputPath()
+		if err != nil {
+			return err
+		}
+	}
+	path, err := checkOutputPath(path)
+	if err != nil {
+		return err
+	}
+	globalConfig.path = path
+	return nil
+}
+
 func getInitialUserInput() error {
+	if !isInteractive() {
+		return resolveNonInteractive()
+	}
 	for globalConfig.name == "" {
 		name, err := getValue("What is your name?", "First Last")
 		if err != nil {
@@ -81,13 +297,18 @@ func getInitialUserInput() error {
 		}
 		fmt.Println("Error: Project Number is required.")
 	}
-	dir, err := os.Getwd()
+	if globalConfig.path != "" {
+		path, err := checkOutputPath(globalConfig.path)
+		if err != nil {
+			// the path from --output is unusable, so ask for another one
+			fmt.Println("Error:", err)
+		}
+		globalConfig.path = path
+	}
+	defaultPath, err := defaultOutputPath()
 	if err != nil {
 		return err
 	}
-	dashConnectedName := strings.Join(strings.Split(strings.ToLower(globalConfig.name), " "), "-")
-	filename := fmt.Sprintf("%s-project%02d.ipynb", dashConnectedName, globalConfig.projectNumber)
-	defaultPath := filepath.Join(dir, filename)
 	for globalConfig.path == "" {
 		resp, err := getValue("Where would you like to store this file?", defaultPath)
 		if err != nil {
@@ -97,22 +318,19 @@ func getInitialUserInput() error {
 		if path == "" {
 			path = defaultPath
 		}
-		path, err = filepath.Abs(path)
-		if err != nil {
-			fmt.Println("There was an error in your path.")
-			continue
+		if format, err := outputFormat(path); err == nil && !strings.EqualFold(filepath.Ext(path), format.Extension) {
+			fmt.Printf("Warning: %s extension not used.\n", format.Extension)
 		}
-		if filepath.Ext(path) != ".ipynb" {
-			fmt.Println("Warning: .ipynb extension not used.")
-		}
-		_, err = os.Open(path)
-		if errors.Is(err, fs.ErrNotExist) {
-			// this is a path we can use!
-			globalConfig.path = path
-		} else {
+		path, err = checkOutputPath(path)
+		if errors.Is(err, errPathExists) {
 			fmt.Println("That path already exists! Pick another one.")
 			continue
+		} else if err != nil {
+			fmt.Println("There was an error in your path.")
+			continue
 		}
+		// this is a path we can use!
+		globalConfig.path = path
 	}
 	return nil
 }
@@ -0,0 +1,47 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"testing"
+
+	"github.com/agarmu/datamine-scraper/model"
+)
+
+func TestIdentifyProject(t *testing.T) {
+	defer func(saved Config) { globalConfig = saved }(globalConfig)
+	project := &model.Project{
+		URL:   "https://the-examples-book.com/projects/current-projects/10100-2023-project04",
+		Title: "TDM 10100: Project 4 -- 2023",
+	}
+	tests := []struct {
+		name   string
+		given  int
+		number int
+	}{
+		{"inferred", -1, 4},
+		{"given", 2, 2},
+	}
+	for _, tt := range tests {
+		globalConfig.projectNumber = tt.given
+		identifyProject(project)
+		if globalConfig.projectNumber != tt.number || globalConfig.course != "10100" || globalConfig.term != "2023" {
+			t.Errorf("%s: got project %d of %s %s, want %d of 10100 2023", tt.name,
+				globalConfig.projectNumber, globalConfig.course, globalConfig.term, tt.number)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package fileutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "project.ipynb")
+	for i, want := range []string{path + ".bak", path + ".bak.1", path + ".bak.2"} {
+		contents := []byte{byte('a' + i)}
+		if err := WriteAtomic(path, contents); err != nil {
+			t.Fatal(err)
+		}
+		got, err := Backup(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("backup %d: got %s, want %s", i, got, want)
+		}
+		data, err := os.ReadFile(got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != string(contents) {
+			t.Errorf("backup %d: got %q, want %q", i, data, contents)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// Package markdown has small helpers for writing Markdown.
+package markdown
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Quote turns text into a Markdown block quote.
+func Quote(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimRight("> "+l, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+var backtickRuns = regexp.MustCompile("`+")
+
+// CodeBlock turns code into a fenced code block with the given info string,
+// e.g. a language. The fence is longer than any run of backticks in the
+// code, so that the code cannot close it.
+func CodeBlock(info string, code string) string {
+	n := 3
+	for _, run := range backtickRuns.FindAllString(code, -1) {
+		if len(run) >= n {
+			n = len(run) + 1
+		}
+	}
+	fence := strings.Repeat("`", n)
+	return fence + info + "\n" + code + "\n" + fence
+}
+
+// imagePattern matches the source of an image, in Markdown (![alt](src)) or
+// in an HTML <img> tag.
+var imagePattern = regexp.MustCompile(`(!\[[^\]]*\]\()([^)\s]+)|(<img\s[^>]*?src=")([^"]+)`)
+
+// ReplaceImages replaces the source of every image in s with f(source).
+func ReplaceImages(s string, f func(src string) string) string {
+	return imagePattern.ReplaceAllStringFunc(s, func(m string) string {
+		sub := imagePattern.FindStringSubmatch(m)
+		if sub[1] != "" {
+			return sub[1] + f(sub[2])
+		}
+		return sub[3] + f(sub[4])
+	})
+}
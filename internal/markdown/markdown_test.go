@@ -0,0 +1,35 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package markdown
+
+import "testing"
+
+func TestCodeBlock(t *testing.T) {
+	tests := []struct {
+		info, code, want string
+	}{
+		{"r", "head(dat)", "```r\nhead(dat)\n```"},
+		{"", "x", "```\nx\n```"},
+		{"bash", "cat <<EOF > README.md\n```python\nprint(1)\n```\nEOF", "````bash\ncat <<EOF > README.md\n```python\nprint(1)\n```\nEOF\n````"},
+		{"", "a ````` b", "``````\na ````` b\n``````"},
+	}
+	for _, tt := range tests {
+		if got := CodeBlock(tt.info, tt.code); got != tt.want {
+			t.Errorf("CodeBlock(%q, %q) = %q, want %q", tt.info, tt.code, got, tt.want)
+		}
+	}
+}
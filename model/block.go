@@ -0,0 +1,50 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package model
+
+// BlockKind is the type of an AsciiDoc block as emitted by Asciidoctor.
+type BlockKind string
+
+const (
+	BlockParagraph  BlockKind = "paragraph"
+	BlockListing    BlockKind = "listing"
+	BlockLiteral    BlockKind = "literal"
+	BlockAdmonition BlockKind = "admonition"
+	BlockImage      BlockKind = "image"
+	BlockTable      BlockKind = "table"
+	BlockList       BlockKind = "list"
+	BlockQuote      BlockKind = "quote"
+	BlockExample    BlockKind = "example"
+	BlockSidebar    BlockKind = "sidebar"
+	BlockVideo      BlockKind = "video"
+	BlockHTML       BlockKind = "html"
+)
+
+// Block is one piece of a question's body. Content is HTML, except for
+// listing and literal blocks where it is the verbatim text.
+type Block struct {
+	Kind     BlockKind `json:"kind" yaml:"kind"`
+	Title    string    `json:"title,omitempty" yaml:"title,omitempty"`
+	Label    string    `json:"label,omitempty" yaml:"label,omitempty"`       // admonition label, e.g. "Note"
+	Language string    `json:"language,omitempty" yaml:"language,omitempty"` // listing language, if given
+	Content  string    `json:"content" yaml:"content"`
+}
+
+// IsHTML reports whether the content goes through the Markdown pipeline.
+func (b Block) IsHTML() bool {
+	return b.Kind != BlockListing && b.Kind != BlockLiteral
+}
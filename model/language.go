@@ -0,0 +1,113 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package model
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Language is a language the code on a project page is written in.
+type Language string
+
+const (
+	LanguagePython Language = "python"
+	LanguageR      Language = "r"
+	LanguageSQL    Language = "sql"
+	LanguageBash   Language = "bash"
+)
+
+// languageNames maps the languages declared on listings to a Language.
+var languageNames = map[string]Language{
+	"python":  LanguagePython,
+	"python3": LanguagePython,
+	"py":      LanguagePython,
+	"ipython": LanguagePython,
+	"r":       LanguageR,
+	"sql":     LanguageSQL,
+	"sqlite":  LanguageSQL,
+	"mysql":   LanguageSQL,
+	"bash":    LanguageBash,
+	"sh":      LanguageBash,
+	"shell":   LanguageBash,
+	"console": LanguageBash,
+}
+
+var (
+	// magicPattern matches the Jupyter magics that switch a cell to another
+	// language, e.g. "%%sql" or "%%R" (rpy2).
+	magicPattern = regexp.MustCompile(`(?m)^\s*%%?(sql|bash|sh|R)\b`)
+	// rPattern matches R idioms in listings that declare no language.
+	rPattern = regexp.MustCompile(`<-|\blibrary\(`)
+	// pythonPattern does the same for Python.
+	pythonPattern = regexp.MustCompile(`(?m)^\s*(import|from \S+ import|def) `)
+)
+
+// ListingLanguage guesses the language of a code listing: a cell magic in it
+// wins over its declared language, which wins over R or Python idioms in the
+// code. It returns "" if there is no telling.
+func ListingLanguage(b Block) Language {
+	if m := magicPattern.FindStringSubmatch(b.Content); m != nil {
+		return languageNames[strings.ToLower(m[1])]
+	}
+	if l, ok := languageNames[strings.ToLower(b.Language)]; ok {
+		return l
+	}
+	switch {
+	case rPattern.MatchString(b.Content):
+		return LanguageR
+	case pythonPattern.MatchString(b.Content):
+		return LanguagePython
+	}
+	return ""
+}
+
+// LanguageCounts counts the code listings of each language in the questions
+// and all of their children.
+func LanguageCounts(qs []Question) map[Language]int {
+	counts := map[Language]int{}
+	countLanguages(qs, counts)
+	return counts
+}
+
+func countLanguages(qs []Question, counts map[Language]int) {
+	for _, q := range qs {
+		for _, b := range q.Body {
+			if b.Kind != BlockListing {
+				continue
+			}
+			if l := ListingLanguage(b); l != "" {
+				counts[l]++
+			}
+		}
+		countLanguages(q.Children, counts)
+	}
+}
+
+// DetectLanguage returns the most common language among the code listings
+// of the questions, or "" if none of them could be told apart. Ties go to
+// the language listed first in the constants above.
+func DetectLanguage(qs []Question) Language {
+	counts := LanguageCounts(qs)
+	best := Language("")
+	for _, l := range []Language{LanguagePython, LanguageR, LanguageSQL, LanguageBash} {
+		if counts[l] > counts[best] {
+			best = l
+		}
+	}
+	return best
+}
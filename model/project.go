@@ -0,0 +1,115 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// Package model holds the data scraped from a project page: the page itself
+// and the tree of questions found on it.
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// Project is a scraped project page.
+type Project struct {
+	URL       string     `json:"url" yaml:"url"`
+	Title     string     `json:"title" yaml:"title"`
+	ScrapedAt time.Time  `json:"scraped_at" yaml:"scraped_at"`
+	Questions []Question `json:"questions" yaml:"questions"`
+	// Images are the images shown in the questions, once downloaded with
+	// scrape.Scraper.FetchImages.
+	Images []Image `json:"-" yaml:"-"`
+}
+
+// Image is an image shown in the questions of a project.
+type Image struct {
+	Source    string // the url as written in the question text
+	Name      string // a file name, unique within the project
+	MediaType string
+	Data      []byte
+}
+
+// Question is a node of the question tree. Top-level questions come from the
+// `.sect2` sections of a page and have a Header; their children come from the
+// section's (arbitrarily nested) lists.
+type Question struct {
+	Header   string     `json:"header,omitempty" yaml:"header,omitempty"`
+	Text     string     `json:"text,omitempty" yaml:"text,omitempty"` // a section's bold description, or a list item's text
+	Body     []Block    `json:"body,omitempty" yaml:"body,omitempty"`
+	Children []Question `json:"children,omitempty" yaml:"children,omitempty"`
+	// Datasets are the paths of the data files under /anvil/projects/tdm/data
+	// that the question itself (not its children) refers to.
+	Datasets []string `json:"datasets,omitempty" yaml:"datasets,omitempty"`
+}
+
+// Hash fingerprints the questions, so that two notebooks can be checked for
+// having been generated from the same page content.
+func Hash(qs []Question) string {
+	data, err := json.Marshal(qs)
+	if err != nil {
+		panic(err)
+	}
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Datasets returns the datasets of all the questions and their children,
+// each listed once in the order they first appear.
+func Datasets(qs []Question) []string {
+	var paths []string
+	seen := map[string]bool{}
+	var walk func(qs []Question)
+	walk = func(qs []Question) {
+		for _, q := range qs {
+			for _, d := range q.Datasets {
+				if !seen[d] {
+					seen[d] = true
+					paths = append(paths, d)
+				}
+			}
+			walk(q.Children)
+		}
+	}
+	walk(qs)
+	return paths
+}
+
+// WalkHTML calls f with a pointer to every HTML fragment in the question
+// tree, so that it can be rewritten in place. Question headers are plain
+// text and are not visited.
+func WalkHTML(qs []Question, f func(text *string) error) error {
+	for i := range qs {
+		q := &qs[i]
+		if err := f(&q.Text); err != nil {
+			return err
+		}
+		for j := range q.Body {
+			if !q.Body[j].IsHTML() {
+				continue
+			}
+			if err := f(&q.Body[j].Content); err != nil {
+				return err
+			}
+		}
+		if err := WalkHTML(q.Children, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// ProjectID identifies a project by course, term and number, which is what
+// the slugs of project pages such as "10100-2023-project01" are made of.
+type ProjectID struct {
+	Course string `json:"course" yaml:"course"` // e.g. "10100"
+	Term   string `json:"term" yaml:"term"`     // e.g. "2023"
+	Number int    `json:"number" yaml:"number"`
+}
+
+var slugPattern = regexp.MustCompile(`\b(\d{5})-(\d{4})-project-?(\d+)\b`)
+
+// ParseSlug finds a project slug in s, which may be a url or a path.
+func ParseSlug(s string) (ProjectID, bool) {
+	m := slugPattern.FindAllStringSubmatch(s, -1)
+	if m == nil {
+		return ProjectID{}, false
+	}
+	// the last one, in case a directory looks like a slug too
+	last := m[len(m)-1]
+	n, err := strconv.Atoi(last[3])
+	if err != nil {
+		return ProjectID{}, false
+	}
+	return ProjectID{Course: last[1], Term: last[2], Number: n}, true
+}
+
+var (
+	titleNumberPattern = regexp.MustCompile(`(?i)\bproject\s*0*(\d+)\b`)
+	titleCoursePattern = regexp.MustCompile(`\b(\d{5})\b`)
+	titleTermPattern   = regexp.MustCompile(`\b(20\d\d)\b`)
+)
+
+// Identify works out which project a page is, from the slug of its url and,
+// for whatever the slug does not tell, from its title (e.g. "TDM 10100:
+// Project 3 -- 2023"). Fields that neither tells are left empty.
+func (p *Project) Identify() ProjectID {
+	id, _ := ParseSlug(p.URL)
+	if id.Number == 0 {
+		if m := titleNumberPattern.FindStringSubmatch(p.Title); m != nil {
+			id.Number, _ = strconv.Atoi(m[1])
+		}
+	}
+	if id.Course == "" {
+		if m := titleCoursePattern.FindStringSubmatch(p.Title); m != nil {
+			id.Course = m[1]
+		}
+	}
+	if id.Term == "" {
+		if m := titleTermPattern.FindStringSubmatch(p.Title); m != nil {
+			id.Term = m[1]
+		}
+	}
+	return id
+}
+
+// Slug returns the slug of the project's page.
+func (id ProjectID) Slug() string {
+	return fmt.Sprintf("%s-%s-project%02d", id.Course, id.Term, id.Number)
+}
@@ -0,0 +1,68 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package model
+
+import "testing"
+
+func TestParseSlug(t *testing.T) {
+	tests := []struct {
+		s    string
+		want ProjectID
+		ok   bool
+	}{
+		{"https://the-examples-book.com/projects/current-projects/10100-2023-project01", ProjectID{"10100", "2023", 1}, true},
+		{"https://the-examples-book.com/projects/current-projects/10100-2023-project01#questions", ProjectID{"10100", "2023", 1}, true},
+		{"/projects/40100-2022-project-12.html", ProjectID{"40100", "2022", 12}, true},
+		{"/home/me/19000-2023-project02/20200-2024-project03.html", ProjectID{"20200", "2024", 3}, true},
+		{"https://the-examples-book.com/projects/current-projects/", ProjectID{}, false},
+		{"/projects/110100-2023-project01", ProjectID{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseSlug(tt.s)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ParseSlug(%q) = %+v, %t; want %+v, %t", tt.s, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestIdentify(t *testing.T) {
+	tests := []struct {
+		url, title string
+		want       ProjectID
+	}{
+		{"https://the-examples-book.com/projects/current-projects/10100-2023-project01", "TDM 20200: Project 5 -- 2024", ProjectID{"10100", "2023", 1}},
+		{"file:///home/me/saved.html", "TDM 20200: Project 5 -- 2024", ProjectID{"20200", "2024", 5}},
+		{"file:///home/me/saved.html", "Project 07", ProjectID{"", "", 7}},
+		{"", "Introduction", ProjectID{}},
+	}
+	for _, tt := range tests {
+		p := &Project{URL: tt.url, Title: tt.title}
+		if got := p.Identify(); got != tt.want {
+			t.Errorf("Identify(%q, %q) = %+v, want %+v", tt.url, tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestSlug(t *testing.T) {
+	id := ProjectID{"10100", "2023", 1}
+	if got := id.Slug(); got != "10100-2023-project01" {
+		t.Errorf("got %q", got)
+	}
+	if got, _ := ParseSlug(id.Slug()); got != id {
+		t.Errorf("round trip: got %+v, want %+v", got, id)
+	}
+}
@@ -0,0 +1,478 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package notebook
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/agarmu/datamine-scraper/internal/markdown"
+)
+
+// SubKey is the key of the item with the given label below the question or
+// item with key parent, e.g. "Question 2/B/iii". Keys tag the cells of an
+// item, but labels shift when an item before it is removed, so Merge goes by
+// the text of the prompts instead.
+func SubKey(parent string, label string) string {
+	return parent + "/" + label
+}
+
+var pointsPattern = regexp.MustCompile(`(?i)\s*[(\[]\s*\d+(?:\.\d+)?\s*(?:pts?|points?)\.?\s*[)\]]`)
+
+// QuestionKey is the key of the question with the given header: the header
+// without the points the question is worth, which may change without it
+// becoming another question.
+func QuestionKey(header string) string {
+	return strings.TrimSpace(pointsPattern.ReplaceAllString(header, ""))
+}
+
+var sourceLinkPattern = regexp.MustCompile(`\[this url\]\(([^)\s]+)\)`)
+
+// SourceURL finds the url a notebook was generated from, falling back to
+// the link in the title cell for notebooks that predate the metadata.
+func (nb Notebook) SourceURL() string {
+	if nb.Metadata.Tdmscrape != nil && nb.Metadata.Tdmscrape.SourceURL != "" {
+		return nb.Metadata.Tdmscrape.SourceURL
+	}
+	for _, c := range nb.Cells {
+		if m := sourceLinkPattern.FindStringSubmatch(c.Source); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+// segment is a prompt cell together with every cell after it up to the next
+// prompt, which is where the student's answers live.
+type segment struct {
+	key    string
+	prompt Cell
+	body   []Cell
+	// removed is the note an earlier Merge put in front of a prompt that was
+	// no longer on the page.
+	removed *Cell
+}
+
+// Layout is a notebook split into the cells before the first prompt, the
+// prompts, and the pledge with everything after it.
+type Layout struct {
+	preamble []Cell
+	segments []segment
+	tail     []Cell
+}
+
+// HasSubSubPrompts reports whether items below subquestions have prompts of
+// their own.
+func (l Layout) HasSubSubPrompts() bool {
+	for _, s := range l.segments {
+		if depth(s.key) >= 2 {
+			return true
+		}
+	}
+	return false
+}
+
+// Split lays out the cells of a notebook by prompt.
+func Split(cells []Cell) Layout {
+	tagged := false
+	for _, c := range cells {
+		if role, _ := CellRole(c); role != "" {
+			tagged = true
+			break
+		}
+	}
+	var l Layout
+	var current *segment
+	inferrer := legacyInferrer{}
+	for i, c := range cells {
+		role, key := CellRole(c)
+		if !tagged {
+			role, key = inferrer.infer(c)
+		}
+		switch {
+		case role == RolePledge:
+			l.tail = cells[i:]
+			return l
+		case role == RolePrompt:
+			s := segment{key: key, prompt: WithRole(c, RolePrompt, key)}
+			if current != nil {
+				s.removed = takeRemovedNote(&current.body, key)
+			} else {
+				s.removed = takeRemovedNote(&l.preamble, key)
+			}
+			l.segments = append(l.segments, s)
+			current = &l.segments[len(l.segments)-1]
+		case current != nil:
+			current.body = append(current.body, c)
+		default:
+			l.preamble = append(l.preamble, c)
+		}
+	}
+	return l
+}
+
+// takeRemovedNote takes the note flagging the prompt with the given key as
+// removed off the end of cells, if it is there.
+func takeRemovedNote(cells *[]Cell, key string) *Cell {
+	n := len(*cells)
+	if n == 0 {
+		return nil
+	}
+	c := (*cells)[n-1]
+	if role, k := CellRole(c); role != RoleFlag || k != key || c.Source != removedNote {
+		return nil
+	}
+	*cells = (*cells)[:n-1]
+	return &c
+}
+
+var (
+	legacySubPattern    = regexp.MustCompile(`^\*\*([A-Z])\. `)
+	legacySubSubPattern = regexp.MustCompile(`^\*([ivxlcdm]+)\. `)
+)
+
+// legacyInferrer recovers prompt keys from the text of notebooks generated
+// before cells were tagged with their role.
+type legacyInferrer struct {
+	question string
+	sub      string
+}
+
+func (l *legacyInferrer) infer(c Cell) (role string, key string) {
+	if c.Type != MarkdownCell {
+		return "", ""
+	}
+	source := strings.TrimSpace(c.Source)
+	if strings.HasPrefix(source, "## ") {
+		header := strings.TrimSpace(strings.SplitN(source[3:], "\n", 2)[0])
+		if header == "Pledge" {
+			return RolePledge, ""
+		}
+		if !strings.Contains(header, "Question") {
+			// e.g. the list of datasets
+			return "", ""
+		}
+		l.question, l.sub = QuestionKey(header), ""
+		return RolePrompt, l.question
+	}
+	if l.question == "" {
+		return "", ""
+	}
+	if m := legacySubPattern.FindStringSubmatch(source); m != nil {
+		l.sub = SubKey(l.question, m[1])
+		return RolePrompt, l.sub
+	}
+	if m := legacySubSubPattern.FindStringSubmatch(source); m != nil && l.sub != "" {
+		return RolePrompt, SubKey(l.sub, m[1])
+	}
+	return "", ""
+}
+
+// Report lists the keys of the prompts that changed in a Merge.
+type Report struct {
+	Added    []string
+	Reworded []string
+	Removed  []string
+}
+
+// Empty reports whether nothing changed.
+func (r Report) Empty() bool {
+	return len(r.Added)+len(r.Reworded)+len(r.Removed) == 0
+}
+
+const (
+	addedNote    = "> **Note (tdmscrape update):** this prompt was added to the project page after this notebook was created."
+	rewordedNote = "> **Note (tdmscrape update):** the wording of this prompt changed on the project page. The previous wording was:\n>\n"
+	removedNote  = "> **Note (tdmscrape update):** the following prompt no longer appears on the project page. Your work on it has been kept."
+)
+
+func flagCell(key string, note string) Cell {
+	return WithRole(NewMarkdownCell(note), RoleFlag, key)
+}
+
+// minSimilarity is how much of its wording a prompt has to keep to be taken
+// for a reworded version of the old one rather than a new prompt.
+const minSimilarity = 0.6
+
+var labelPattern = regexp.MustCompile(`^((?:&emsp;)*\*{1,2})(?:[A-Z]{1,2}|[a-z]{1,2}|[ivxlcdm]+|\d+)\. `)
+
+// promptText is the text of a prompt cell without what depends on its place
+// on the page: the labels of the items and the points of the question.
+func promptText(source string) string {
+	lines := strings.Split(source, "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "## ") {
+			line = "## " + QuestionKey(line[3:])
+		}
+		lines[i] = labelPattern.ReplaceAllString(line, "$1")
+	}
+	return strings.Join(strings.Fields(strings.Join(lines, " ")), " ")
+}
+
+// similarity is the share of words two texts have in common, from 0 for
+// none to 1 for all of them.
+func similarity(a string, b string) float64 {
+	wordsA, wordsB := strings.Fields(strings.ToLower(a)), strings.Fields(strings.ToLower(b))
+	if len(wordsA)+len(wordsB) == 0 {
+		return 1
+	}
+	counts := map[string]int{}
+	for _, w := range wordsA {
+		counts[w]++
+	}
+	common := 0
+	for _, w := range wordsB {
+		if counts[w] > 0 {
+			counts[w]--
+			common++
+		}
+	}
+	return 2 * float64(common) / float64(len(wordsA)+len(wordsB))
+}
+
+// removedPrefix starts the keys of prompts that are no longer on the page,
+// which keeps them apart from the prompts that took their labels.
+const removedPrefix = "removed/"
+
+func depth(key string) int {
+	return strings.Count(strings.TrimPrefix(key, removedPrefix), "/")
+}
+
+// parents returns the index of the parent of every segment, -1 for
+// questions and items whose parent has no prompt.
+func parents(segments []segment) []int {
+	index := map[string]int{}
+	out := make([]int, len(segments))
+	for i, s := range segments {
+		if _, dup := index[s.key]; !dup {
+			index[s.key] = i
+		}
+		out[i] = -1
+		if j := strings.LastIndex(s.key, "/"); j >= 0 {
+			if p, ok := index[s.key[:j]]; ok {
+				out[i] = p
+			}
+		}
+	}
+	return out
+}
+
+// match pairs the fresh segments with the old ones they are a version of,
+// returning the index of the old segment for every fresh one, or -1. Prompts
+// are matched by their text, identical text first, then the most similar,
+// level by level so that items prefer the items of the question they were
+// matched with.
+func match(old []segment, fresh []segment) []int {
+	oldTexts, freshTexts := make([]string, len(old)), make([]string, len(fresh))
+	for i, s := range old {
+		oldTexts[i] = promptText(s.prompt.Source)
+	}
+	for i, s := range fresh {
+		freshTexts[i] = promptText(s.prompt.Source)
+	}
+	oldParents, freshParents := parents(old), parents(fresh)
+	matched := make([]int, len(fresh))
+	for i := range matched {
+		matched[i] = -1
+	}
+	taken := make([]bool, len(old))
+	type candidate struct {
+		fresh, old int
+		sameParent bool
+		score      float64
+	}
+	maxDepth := 0
+	for _, s := range fresh {
+		if d := depth(s.key); d > maxDepth {
+			maxDepth = d
+		}
+	}
+	for d := 0; d <= maxDepth; d++ {
+		var candidates []candidate
+		for f := range fresh {
+			if depth(fresh[f].key) != d {
+				continue
+			}
+			for o := range old {
+				if depth(old[o].key) != d {
+					continue
+				}
+				score := 2.0
+				if oldTexts[o] != freshTexts[f] {
+					score = similarity(oldTexts[o], freshTexts[f])
+				}
+				if score < minSimilarity {
+					continue
+				}
+				sameParent := freshParents[f] < 0 && oldParents[o] < 0 ||
+					freshParents[f] >= 0 && matched[freshParents[f]] == oldParents[o]
+				candidates = append(candidates, candidate{f, o, sameParent, score})
+			}
+		}
+		sort.SliceStable(candidates, func(i, j int) bool {
+			a, b := candidates[i], candidates[j]
+			if a.sameParent != b.sameParent {
+				return a.sameParent
+			}
+			if a.score != b.score {
+				return a.score > b.score
+			}
+			return abs(a.fresh-a.old) < abs(b.fresh-b.old)
+		})
+		for _, c := range candidates {
+			if matched[c.fresh] < 0 && !taken[c.old] {
+				matched[c.fresh] = c.old
+				taken[c.old] = true
+			}
+		}
+	}
+	return matched
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// rekey moves a cell tagged with key from over to key to, for the cells of
+// an item whose label changed.
+func rekey(c Cell, from string, to string) Cell {
+	tag, ok := c.Metadata["tdmscrape"].(map[string]interface{})
+	if !ok || from == to || tag["key"] != from {
+		return c
+	}
+	metadata := map[string]interface{}{}
+	for k, v := range c.Metadata {
+		metadata[k] = v
+	}
+	retagged := map[string]interface{}{}
+	for k, v := range tag {
+		retagged[k] = v
+	}
+	retagged["key"] = to
+	metadata["tdmscrape"] = retagged
+	c.Metadata = metadata
+	return c
+}
+
+func rekeyAll(cells []Cell, from string, to string) []Cell {
+	out := make([]Cell, len(cells))
+	for i, c := range cells {
+		out[i] = rekey(c, from, to)
+	}
+	return out
+}
+
+// dropFlags returns cells without the notes flagging the prompt with the
+// given key.
+func dropFlags(cells []Cell, key string) []Cell {
+	var out []Cell
+	for _, c := range cells {
+		if role, k := CellRole(c); role == RoleFlag && k == key {
+			continue
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// Merge lays the old notebook's cells out along the freshly generated
+// skeleton. Student cells are never dropped: prompts that disappeared from
+// the page stay where they were, behind a note.
+func Merge(old Layout, fresh Layout) ([]Cell, Report) {
+	var report Report
+	matched := match(old.segments, fresh.segments)
+	// removed segments are anchored after the fresh prompt matching the last
+	// surviving one before them
+	freshIndex := make([]int, len(old.segments))
+	for i := range freshIndex {
+		freshIndex[i] = -1
+	}
+	for f, o := range matched {
+		if o >= 0 {
+			freshIndex[o] = f
+		}
+	}
+	orphans := map[int][]segment{}
+	anchor := -1
+	for o, s := range old.segments {
+		if freshIndex[o] >= 0 {
+			anchor = freshIndex[o]
+			continue
+		}
+		orphans[anchor] = append(orphans[anchor], s)
+	}
+	appendOrphans := func(cells []Cell, anchor int) []Cell {
+		for _, s := range orphans[anchor] {
+			key := s.key
+			if !strings.HasPrefix(key, removedPrefix) {
+				key = removedPrefix + key
+			}
+			if s.removed != nil {
+				// flagged by an earlier update already
+				cells = append(cells, rekey(*s.removed, s.key, key))
+			} else {
+				report.Removed = append(report.Removed, s.key)
+				cells = append(cells, flagCell(key, removedNote))
+			}
+			cells = append(cells, rekey(s.prompt, s.key, key))
+			cells = append(cells, rekeyAll(s.body, s.key, key)...)
+		}
+		return cells
+	}
+
+	cells := append([]Cell{}, old.preamble...)
+	if len(cells) == 0 {
+		cells = append(cells, fresh.preamble...)
+	}
+	cells = appendOrphans(cells, -1)
+	for f, s := range fresh.segments {
+		if matched[f] < 0 {
+			report.Added = append(report.Added, s.key)
+			cells = append(cells, s.prompt, flagCell(s.key, addedNote))
+			cells = append(cells, s.body...)
+			cells = appendOrphans(cells, f)
+			continue
+		}
+		prev := old.segments[matched[f]]
+		body := prev.body
+		prompt := rekey(prev.prompt, prev.key, s.key)
+		prompt.Source = s.prompt.Source
+		if promptText(prev.prompt.Source) == promptText(s.prompt.Source) {
+			// at most the label or the points changed; the notes of an
+			// earlier update still hold
+			cells = append(cells, prompt)
+		} else {
+			// the notes of an earlier update are replaced by this one's
+			body = dropFlags(body, prev.key)
+			report.Reworded = append(report.Reworded, s.key)
+			cells = append(cells, prompt, flagCell(s.key, rewordedNote+markdown.Quote(prev.prompt.Source)))
+		}
+		cells = append(cells, rekeyAll(body, prev.key, s.key)...)
+		cells = appendOrphans(cells, f)
+	}
+	if len(old.tail) > 0 {
+		cells = append(cells, old.tail...)
+	} else {
+		cells = append(cells, fresh.tail...)
+	}
+	return cells, report
+}
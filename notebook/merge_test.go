@@ -0,0 +1,172 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package notebook
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+func readTestNotebook(t *testing.T, name string) Notebook {
+	t.Helper()
+	nb, err := Read(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return nb
+}
+
+// summary lists the cells one after the other with their role and key.
+func summary(cells []Cell) []byte {
+	var b bytes.Buffer
+	for _, c := range cells {
+		role, key := CellRole(c)
+		fmt.Fprintf(&b, "--- %s %s %s\n%s\n", c.Type, role, key, c.Source)
+	}
+	return b.Bytes()
+}
+
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs:\n%s", path, got)
+	}
+}
+
+// The fresh notebook was generated from the page of the answered one after
+// the points of Question 1 changed, its first item was removed (so that the
+// next one took its label), an item was reworded and Question 3 was added.
+func TestMerge(t *testing.T) {
+	answered, fresh := readTestNotebook(t, "answered.ipynb"), readTestNotebook(t, "fresh.ipynb")
+	cells, report := Merge(Split(answered.Cells), Split(fresh.Cells))
+	want := Report{
+		Added:    []string{"Question 3"},
+		Reworded: []string{"Question 1/A/i"},
+		Removed:  []string{"Question 1/A"},
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("got report %+v, want %+v", report, want)
+	}
+	golden(t, "merged.txt", summary(cells))
+
+	again, report := Merge(Split(cells), Split(fresh.Cells))
+	if !report.Empty() {
+		t.Errorf("merging again reported %+v", report)
+	}
+	if got, want := summary(again), summary(cells); !bytes.Equal(got, want) {
+		t.Errorf("merging again changed the notebook:\n%s", got)
+	}
+	checkUniqueKeys(t, cells)
+}
+
+// The page changed once more after the update: the reworded item and the
+// added question were both reworded again.
+func TestMergeTwice(t *testing.T) {
+	answered, fresh := readTestNotebook(t, "answered.ipynb"), readTestNotebook(t, "fresh.ipynb")
+	updated, _ := Merge(Split(answered.Cells), Split(fresh.Cells))
+	changed := readTestNotebook(t, "changed-again.ipynb")
+	cells, report := Merge(Split(updated), Split(changed.Cells))
+	want := Report{Reworded: []string{"Question 1/A/i", "Question 3"}}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("got report %+v, want %+v", report, want)
+	}
+	golden(t, "merged-twice.txt", summary(cells))
+	checkUniqueKeys(t, cells)
+}
+
+// checkUniqueKeys fails if two prompts have the same key.
+func checkUniqueKeys(t *testing.T, cells []Cell) {
+	t.Helper()
+	seen := map[string]bool{}
+	for _, c := range cells {
+		if role, key := CellRole(c); role == RolePrompt {
+			if seen[key] {
+				t.Errorf("two prompts have the key %q", key)
+			}
+			seen[key] = true
+		}
+	}
+}
+
+// Notebooks from before cells were tagged are split by the text of their
+// prompts instead.
+func TestMergeUntagged(t *testing.T) {
+	answered, fresh := readTestNotebook(t, "answered.ipynb"), readTestNotebook(t, "fresh.ipynb")
+	for i := range answered.Cells {
+		delete(answered.Cells[i].Metadata, "tdmscrape")
+	}
+	_, report := Merge(Split(answered.Cells), Split(fresh.Cells))
+	want := Report{
+		Added:    []string{"Question 3"},
+		Reworded: []string{"Question 1/A/i"},
+		Removed:  []string{"Question 1/A"},
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("got report %+v, want %+v", report, want)
+	}
+}
+
+func TestQuestionKey(t *testing.T) {
+	tests := []struct {
+		header, want string
+	}{
+		{"Question 1", "Question 1"},
+		{"Question 1 (2 pts)", "Question 1"},
+		{"Question 2 (1.5 points)", "Question 2"},
+		{"Question 3 [2 pt]", "Question 3"},
+		{"Question 4 (optional)", "Question 4 (optional)"},
+	}
+	for _, tt := range tests {
+		if got := QuestionKey(tt.header); got != tt.want {
+			t.Errorf("QuestionKey(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestPromptText(t *testing.T) {
+	tests := []struct {
+		a, b string
+	}{
+		{"**A. Load the data.**", "**C. Load the data.**"},
+		{"**Z. Load the data.**", "**AB. Load the data.**"},
+		{"&emsp;*ii. Label the axes.*", "&emsp;*iv. Label the axes.*"},
+		{"## Question 1 (2 pts)\n\n**Load the data.**", "## Question 1 (3 pts)\n\n**Load  the data.**"},
+	}
+	for _, tt := range tests {
+		if a, b := promptText(tt.a), promptText(tt.b); a != b {
+			t.Errorf("%q and %q differ: %q, %q", tt.a, tt.b, a, b)
+		}
+	}
+}
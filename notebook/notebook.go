@@ -0,0 +1,357 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// Package notebook reads and writes Jupyter notebooks (nbformat 4) and lays
+// the cells of a generated skeleton out along those of an existing notebook.
+package notebook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Notebook is an in-memory Jupyter notebook following nbformat 4.x.
+type Notebook struct {
+	Cells         []Cell           `json:"cells"`
+	Metadata      NotebookMetadata `json:"metadata"`
+	Nbformat      int              `json:"nbformat"`
+	NbformatMinor int              `json:"nbformat_minor"`
+}
+
+// NotebookMetadata holds the notebook-level metadata that tdmscrape knows
+// about. Anything else found in a notebook that was read back in is kept in
+// Extra so that it survives a rewrite.
+type NotebookMetadata struct {
+	Kernelspec   *Kernelspec
+	LanguageInfo *LanguageInfo
+	Tdmscrape    *ScrapeMetadata
+	Extra        map[string]json.RawMessage
+}
+
+// ScrapeMetadata records where a generated notebook came from, so that later
+// runs (and TAs) can tell which version of a project page it was built from.
+type ScrapeMetadata struct {
+	SourceURL   string            `json:"source_url"`
+	ScrapedAt   time.Time         `json:"scraped_at"`
+	ToolVersion string            `json:"tool_version"`
+	ToolCommit  string            `json:"tool_commit"`
+	ContentHash string            `json:"content_hash"`
+	Options     GenerationOptions `json:"options"`
+}
+
+// GenerationOptions are the settings a skeleton was generated with, enough to
+// generate it again from the same page.
+type GenerationOptions struct {
+	SubSubQuestionsOwnBlocks bool   `json:"sub_sub_questions_own_blocks"`
+	Writer                   string `json:"writer"`
+	Format                   string `json:"format,omitempty"`
+	Kernel                   string `json:"kernel,omitempty"`
+	// Template is the path of the template the skeleton was laid out with
+	// and TemplateHash the hash of its contents, both empty for the default.
+	Template      string `json:"template,omitempty"`
+	TemplateHash  string `json:"template_hash,omitempty"`
+	Starters      bool   `json:"starters"`
+	Images        string `json:"images"`
+	CheckDatasets bool   `json:"check_datasets"`
+}
+
+type Kernelspec struct {
+	DisplayName string `json:"display_name"`
+	Language    string `json:"language"`
+	Name        string `json:"name"`
+}
+
+type LanguageInfo struct {
+	Name string `json:"name"`
+}
+
+type CellType string
+
+const (
+	MarkdownCell CellType = "markdown"
+	CodeCell     CellType = "code"
+)
+
+// Cell is a single notebook cell. Source is kept as one string and split
+// into lines only when serialized. Outputs, execution counts and attachments
+// are carried through untouched for notebooks that are read back in.
+type Cell struct {
+	ID             string
+	Type           CellType
+	Source         string
+	Metadata       map[string]interface{}
+	Outputs        json.RawMessage
+	ExecutionCount json.RawMessage
+	Attachments    json.RawMessage
+}
+
+// Cell roles recorded in the cell metadata of generated notebooks.
+const (
+	RoleTitle         = "title"
+	RoleCollaboration = "collaboration"
+	RoleDatasets      = "datasets"
+	RoleSetup         = "setup"
+	RolePrompt        = "prompt"
+	RoleAnswer        = "answer"
+	RoleNotes         = "notes"
+	RoleFlag          = "flag"
+	RolePledge        = "pledge"
+)
+
+// DefaultKernelspec is the kernel generated notebooks use unless told
+// otherwise.
+var DefaultKernelspec = Kernelspec{
+	DisplayName: "Python 3 (ipykernel)",
+	Language:    "python",
+	Name:        "python3",
+}
+
+// RKernelspec is the IRkernel, for notebooks in R.
+var RKernelspec = Kernelspec{
+	DisplayName: "R",
+	Language:    "R",
+	Name:        "ir",
+}
+
+// New returns an empty nbformat 4.5 notebook with the given cells.
+func New(cells []Cell) Notebook {
+	return Notebook{
+		Cells:         cells,
+		Nbformat:      4,
+		NbformatMinor: 5,
+	}
+}
+
+func NewMarkdownCell(source string) Cell {
+	return Cell{ID: newCellID(), Type: MarkdownCell, Source: source}
+}
+
+func NewCodeCell(source string) Cell {
+	return Cell{ID: newCellID(), Type: CodeCell, Source: source}
+}
+
+// WithRole tags the cell with its role in the skeleton and, for cells that
+// belong to a question, the key of that question.
+func WithRole(c Cell, role string, key string) Cell {
+	tag := map[string]interface{}{"role": role}
+	if key != "" {
+		tag["key"] = key
+	}
+	if c.Metadata == nil {
+		c.Metadata = map[string]interface{}{}
+	}
+	c.Metadata["tdmscrape"] = tag
+	return c
+}
+
+// CellRole returns the role and key recorded by WithRole, if any.
+func CellRole(c Cell) (role string, key string) {
+	tag, ok := c.Metadata["tdmscrape"].(map[string]interface{})
+	if !ok {
+		return "", ""
+	}
+	role, _ = tag["role"].(string)
+	key, _ = tag["key"].(string)
+	return role, key
+}
+
+// WithLanguage records that a code cell is written in another language than
+// the notebook's kernel, e.g. "sql" for a cell to be run with a %%sql magic.
+func WithLanguage(c Cell, language string) Cell {
+	tag, ok := c.Metadata["tdmscrape"].(map[string]interface{})
+	if !ok {
+		tag = map[string]interface{}{}
+	}
+	tag["language"] = language
+	if c.Metadata == nil {
+		c.Metadata = map[string]interface{}{}
+	}
+	c.Metadata["tdmscrape"] = tag
+	return c
+}
+
+// CellLanguage returns the language recorded by WithLanguage, if any.
+func CellLanguage(c Cell) string {
+	tag, _ := c.Metadata["tdmscrape"].(map[string]interface{})
+	language, _ := tag["language"].(string)
+	return language
+}
+
+// newCellID returns a random identifier that satisfies the nbformat 4.5
+// cell id constraints (1-64 characters of [a-zA-Z0-9-_]).
+func newCellID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// sourceLines splits source the way Jupyter stores it: every line keeps its
+// trailing newline except the last one.
+func sourceLines(source string) []string {
+	lines := []string{}
+	for source != "" {
+		i := strings.IndexByte(source, '\n')
+		if i < 0 {
+			lines = append(lines, source)
+			break
+		}
+		lines = append(lines, source[:i+1])
+		source = source[i+1:]
+	}
+	return lines
+}
+
+func (c Cell) MarshalJSON() ([]byte, error) {
+	metadata := c.Metadata
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	out := map[string]interface{}{
+		"id":        c.ID,
+		"cell_type": c.Type,
+		"metadata":  metadata,
+		"source":    sourceLines(c.Source),
+	}
+	if c.Type == CodeCell {
+		// code cells must always carry these, even when empty
+		out["execution_count"] = nil
+		out["outputs"] = []interface{}{}
+		if c.ExecutionCount != nil {
+			out["execution_count"] = c.ExecutionCount
+		}
+		if c.Outputs != nil {
+			out["outputs"] = c.Outputs
+		}
+	}
+	if c.Attachments != nil {
+		out["attachments"] = c.Attachments
+	}
+	return json.Marshal(out)
+}
+
+func (c *Cell) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		ID             string                 `json:"id"`
+		Type           CellType               `json:"cell_type"`
+		Metadata       map[string]interface{} `json:"metadata"`
+		Source         json.RawMessage        `json:"source"`
+		Outputs        json.RawMessage        `json:"outputs"`
+		ExecutionCount json.RawMessage        `json:"execution_count"`
+		Attachments    json.RawMessage        `json:"attachments"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*c = Cell{
+		ID:             raw.ID,
+		Type:           raw.Type,
+		Metadata:       raw.Metadata,
+		Outputs:        raw.Outputs,
+		ExecutionCount: raw.ExecutionCount,
+		Attachments:    raw.Attachments,
+	}
+	if c.ID == "" {
+		// notebooks older than nbformat 4.5 have no cell ids
+		c.ID = newCellID()
+	}
+	// source may be either a single string or a list of lines
+	var lines []string
+	if err := json.Unmarshal(raw.Source, &lines); err != nil {
+		var source string
+		if err := json.Unmarshal(raw.Source, &source); err != nil {
+			return err
+		}
+		lines = []string{source}
+	}
+	c.Source = strings.Join(lines, "")
+	return nil
+}
+
+func (m NotebookMetadata) MarshalJSON() ([]byte, error) {
+	out := map[string]interface{}{}
+	for k, v := range m.Extra {
+		out[k] = v
+	}
+	if m.Kernelspec != nil {
+		out["kernelspec"] = m.Kernelspec
+	}
+	if m.LanguageInfo != nil {
+		out["language_info"] = m.LanguageInfo
+	}
+	if m.Tdmscrape != nil {
+		out["tdmscrape"] = m.Tdmscrape
+	}
+	return json.Marshal(out)
+}
+
+func (m *NotebookMetadata) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*m = NotebookMetadata{}
+	// language_info carries many optional fields, so it is left in Extra
+	if ks, ok := raw["kernelspec"]; ok {
+		m.Kernelspec = &Kernelspec{}
+		if err := json.Unmarshal(ks, m.Kernelspec); err != nil {
+			return err
+		}
+		delete(raw, "kernelspec")
+	}
+	if tdm, ok := raw["tdmscrape"]; ok {
+		m.Tdmscrape = &ScrapeMetadata{}
+		if err := json.Unmarshal(tdm, m.Tdmscrape); err != nil {
+			return err
+		}
+		delete(raw, "tdmscrape")
+	}
+	m.Extra = raw
+	return nil
+}
+
+// Read loads a notebook from disk.
+func Read(path string) (Notebook, error) {
+	var nb Notebook
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nb, err
+	}
+	if err := json.Unmarshal(data, &nb); err != nil {
+		return nb, fmt.Errorf("%s is not a valid notebook: %w", path, err)
+	}
+	if nb.Nbformat != 4 {
+		return nb, fmt.Errorf("%s uses nbformat %d, only version 4 is supported", path, nb.Nbformat)
+	}
+	return nb, nil
+}
+
+// Encode serializes the notebook the way Jupyter itself does: one-space
+// indentation and a trailing newline.
+func (nb Notebook) Encode() ([]byte, error) {
+	data, err := json.MarshalIndent(nb, "", " ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
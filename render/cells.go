@@ -0,0 +1,102 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package render
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/agarmu/datamine-scraper/internal/markdown"
+	"github.com/agarmu/datamine-scraper/model"
+	rom "github.com/brandenc40/romannumeral"
+)
+
+// toLetters letters the (0-based) index-th item: A to Z, then AA, AB and so
+// on, like spreadsheet columns.
+func toLetters(index int) string {
+	var letters []byte
+	for n := index + 1; n > 0; n = (n - 1) / 26 {
+		letters = append([]byte{byte('A' + (n-1)%26)}, letters...)
+	}
+	return string(letters)
+}
+
+// toRoman returns the lower-case roman numeral for the (1-based) number n.
+func toRoman(n int) (string, error) {
+	roman, err := rom.IntToString(n)
+	if err != nil {
+		return "", fmt.Errorf("failed to convert %d to roman: %w", n, err)
+	}
+	return strings.ToLower(roman), nil
+}
+
+// itemLabel numbers the index-th (0-based) item of a list at the given depth:
+// A, B, ... for subquestions, then i, ii, ..., then a, b, ..., then 1, 2, ...,
+// starting over for anything deeper.
+func itemLabel(depth int, index int) (string, error) {
+	switch (depth - 1) % 4 {
+	case 0:
+		return toLetters(index), nil
+	case 1:
+		return toRoman(index + 1)
+	case 2:
+		return strings.ToLower(toLetters(index)), nil
+	default:
+		return strconv.Itoa(index + 1), nil
+	}
+}
+
+// sourceLink describes where the questions came from for the title cell.
+func sourceLink(p *model.Project) string {
+	if p.URL == "" {
+		return "the project page"
+	}
+	return fmt.Sprintf("[this url](%s)", p.URL)
+}
+
+func title(opts Options) string {
+	return fmt.Sprintf("Project %d -- %s", opts.ProjectNumber, opts.Name)
+}
+
+// attribution credits the scraper and links the project page.
+func attribution(p *model.Project) string {
+	return fmt.Sprintf("_This skeleton for this file was generated by [the TDM Scraper made by Mukul Agarwal](https://github.com/agarmu/datamine-scraper) from the contents of %s._", sourceLink(p))
+}
+
+// renderBlock formats a block (after the Markdown pipeline) for a prompt cell.
+func renderBlock(b model.Block) string {
+	var out string
+	switch b.Kind {
+	case model.BlockListing, model.BlockLiteral:
+		out = markdown.CodeBlock(b.Language, b.Content)
+	case model.BlockAdmonition:
+		label := b.Label
+		if label == "" {
+			label = "Note"
+		}
+		out = markdown.Quote(fmt.Sprintf("**%s:** %s", label, b.Content))
+	case model.BlockQuote, model.BlockSidebar, model.BlockExample:
+		out = markdown.Quote(b.Content)
+	default:
+		out = b.Content
+	}
+	if b.Title != "" {
+		out = fmt.Sprintf("*%s*\n\n%s", b.Title, out)
+	}
+	return out
+}
@@ -0,0 +1,50 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package render
+
+import "testing"
+
+func TestItemLabel(t *testing.T) {
+	tests := []struct {
+		depth, index int
+		want         string
+	}{
+		{1, 0, "A"},
+		{1, 25, "Z"},
+		{1, 26, "AA"},
+		{1, 27, "AB"},
+		{1, 51, "AZ"},
+		{1, 52, "BA"},
+		{1, 701, "ZZ"},
+		{1, 702, "AAA"},
+		{2, 0, "i"},
+		{2, 3, "iv"},
+		{3, 0, "a"},
+		{3, 26, "aa"},
+		{4, 9, "10"},
+		{5, 27, "AB"},
+	}
+	for _, tt := range tests {
+		got, err := itemLabel(tt.depth, tt.index)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("itemLabel(%d, %d) = %q, want %q", tt.depth, tt.index, got, tt.want)
+		}
+	}
+}
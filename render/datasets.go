@@ -0,0 +1,45 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package render
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/agarmu/datamine-scraper/model"
+)
+
+// datasetCheck returns code that reports which of the datasets can be
+// found, in the language of the notebook. Paths may be globs.
+func datasetCheck(paths []string, lang model.Language) string {
+	if len(paths) == 0 {
+		return ""
+	}
+	quoted := make([]string, len(paths))
+	for i, p := range paths {
+		quoted[i] = fmt.Sprintf("    %q", p)
+	}
+	if lang == model.LanguageR {
+		return "datasets <- c(\n" + strings.Join(quoted, ",\n") + "\n)\n" +
+			"for (path in datasets) {\n" +
+			"    cat(if (length(Sys.glob(path)) > 0) \"found  \" else \"MISSING\", path, \"\\n\")\n" +
+			"}"
+	}
+	return "import glob\n\ndatasets = [\n" + strings.Join(quoted, ",\n") + ",\n]\n" +
+		"for path in datasets:\n" +
+		"    print(\"found  \" if glob.glob(path) else \"MISSING\", path)"
+}
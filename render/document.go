@@ -0,0 +1,103 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/agarmu/datamine-scraper/model"
+	"github.com/agarmu/datamine-scraper/notebook"
+)
+
+func init() {
+	Register(Format{Name: "rmd", Extension: ".Rmd", Renderer: RMarkdown{}})
+	Register(Format{Name: "qmd", Extension: ".qmd", Renderer: Quarto{}})
+}
+
+// RMarkdown writes R Markdown documents to be knit to HTML.
+type RMarkdown struct{}
+
+func (RMarkdown) Render(w io.Writer, p *model.Project, opts Options) error {
+	return writeDocument(w, p, opts, "output: html_document", "rmd")
+}
+
+// Quarto writes Quarto documents to be rendered to HTML.
+type Quarto struct{}
+
+func (Quarto) Render(w io.Writer, p *model.Project, opts Options) error {
+	return writeDocument(w, p, opts, "format: html", "qmd")
+}
+
+// writeDocument lays the cells out as a Markdown document with a chunk for
+// every code cell, in R unless a kernel is given, or in the language of the
+// cell. The title moves into the YAML front matter, along with the output
+// settings and the provenance block.
+func writeDocument(w io.Writer, p *model.Project, opts Options, output string, writer string) error {
+	if opts.Kernel == "" && opts.Language == "" {
+		opts.Language = model.LanguageR
+	}
+	opts.knitr = true
+	cells, data, err := layout(p, opts)
+	if err != nil {
+		return err
+	}
+	// JSON strings and objects are valid YAML, which saves quoting by hand
+	heading, err := json.Marshal(title(opts))
+	if err != nil {
+		return err
+	}
+	provenance, err := json.Marshal(Provenance(p, opts, writer))
+	if err != nil {
+		return err
+	}
+	engine := kernelLanguage(p, opts)
+	var doc bytes.Buffer
+	fmt.Fprintf(&doc, "---\ntitle: %s\n%s\ntdmscrape: %s\n---\n", heading, output, provenance)
+	for _, c := range cells {
+		source := c.Source
+		role, _ := notebook.CellRole(c)
+		if role == notebook.RoleTitle {
+			source = attribution(p)
+		}
+		if c.Type == notebook.CodeCell {
+			language := notebook.CellLanguage(c)
+			if language == "" {
+				language = string(engine)
+			}
+			chunkOptions := ""
+			if data.Connection != "" && language == string(model.LanguageSQL) {
+				if engine == model.LanguageR {
+					// SQL chunks run on the connection of the starters
+					chunkOptions = ", connection=" + connectionName
+				} else {
+					// the starter queries the connection from Python
+					language = string(engine)
+				}
+			}
+			// the chunk engine takes the place of a cell magic
+			source = strings.TrimPrefix(source, "%%"+language)
+			source = "```{" + language + chunkOptions + "}\n" + strings.TrimPrefix(source, "\n") + "\n```"
+		}
+		fmt.Fprintf(&doc, "\n%s\n", source)
+	}
+	_, err = w.Write(doc.Bytes())
+	return err
+}
@@ -0,0 +1,75 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package render
+
+import (
+	"encoding/json"
+	"path"
+
+	"github.com/agarmu/datamine-scraper/internal/markdown"
+	"github.com/agarmu/datamine-scraper/model"
+	"github.com/agarmu/datamine-scraper/notebook"
+)
+
+// ImageMode is what becomes of the images shown in the questions.
+type ImageMode string
+
+const (
+	// ImagesSkip leaves images on the website, linking to them there.
+	ImagesSkip ImageMode = "skip"
+	// ImagesLink links to copies of the images in Options.ImageDir.
+	ImagesLink ImageMode = "link"
+	// ImagesEmbed attaches the images to the cells showing them. Only
+	// notebooks support attachments.
+	ImagesEmbed ImageMode = "embed"
+)
+
+// placeImages points the images in the Markdown cells to the downloaded
+// copies in p.Images. Images that were not downloaded are left alone.
+func placeImages(cells []notebook.Cell, p *model.Project, opts Options) error {
+	images := map[string]model.Image{}
+	for _, image := range p.Images {
+		images[image.Source] = image
+	}
+	for i, c := range cells {
+		if c.Type != notebook.MarkdownCell {
+			continue
+		}
+		attachments := map[string]map[string][]byte{}
+		c.Source = markdown.ReplaceImages(c.Source, func(src string) string {
+			image, ok := images[src]
+			if !ok {
+				return src
+			}
+			if opts.Images == ImagesEmbed {
+				attachments[image.Name] = map[string][]byte{image.MediaType: image.Data}
+				return "attachment:" + image.Name
+			}
+			return path.Join(opts.ImageDir, image.Name)
+		})
+		if len(attachments) > 0 {
+			// []byte is encoded as base64, as nbformat wants
+			data, err := json.Marshal(attachments)
+			if err != nil {
+				return err
+			}
+			c.Attachments = data
+		}
+		cells[i] = c
+	}
+	return nil
+}
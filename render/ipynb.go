@@ -0,0 +1,105 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+
+	"github.com/agarmu/datamine-scraper/model"
+	"github.com/agarmu/datamine-scraper/notebook"
+)
+
+func init() {
+	Register(Format{Name: "ipynb", Extension: ".ipynb", Renderer: Native{}})
+}
+
+// Native writes Jupyter notebooks directly.
+type Native struct{}
+
+func (Native) Render(w io.Writer, p *model.Project, opts Options) error {
+	nb, err := NewNotebook(p, opts)
+	if err != nil {
+		return err
+	}
+	data, err := nb.Encode()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// NewNotebook builds the notebook for a project, metadata and all.
+func NewNotebook(p *model.Project, opts Options) (notebook.Notebook, error) {
+	cells, err := Cells(p, opts)
+	if err != nil {
+		return notebook.Notebook{}, err
+	}
+	kernelspec := Kernelspec(p, opts)
+	nb := notebook.New(cells)
+	nb.Metadata = notebook.NotebookMetadata{
+		Kernelspec:   &kernelspec,
+		LanguageInfo: &notebook.LanguageInfo{Name: kernelspec.Language},
+		Tdmscrape:    Provenance(p, opts, "native"),
+	}
+	return nb, nil
+}
+
+// Pandoc renders the cells as pandoc fenced divs and lets pandoc do the
+// conversion to ipynb. It needs pandoc to be installed.
+type Pandoc struct{}
+
+func (Pandoc) Render(w io.Writer, p *model.Project, opts Options) error {
+	// check for pandoc
+	if err := exec.Command("pandoc", "--version").Run(); err != nil {
+		return fmt.Errorf("unable to execute pandoc: %w", err)
+	}
+	cells, err := Cells(p, opts)
+	if err != nil {
+		return err
+	}
+	// JSON is valid YAML, so the provenance block can be passed through as is
+	provenance, err := json.Marshal(Provenance(p, opts, "pandoc"))
+	if err != nil {
+		return err
+	}
+	kernelspec, err := json.Marshal(Kernelspec(p, opts))
+	if err != nil {
+		return err
+	}
+	var doc bytes.Buffer
+	fmt.Fprintf(&doc, `---
+title: My notebook
+jupyter:
+  nbformat: 4
+  nbformat_minor: 5
+  kernelspec: %s
+  tdmscrape: %s
+---
+`, kernelspec, provenance)
+	for _, c := range cells {
+		fmt.Fprintf(&doc, "\n:::::: {.cell .%s}\n%s\n::::::\n", c.Type, c.Source)
+	}
+	cmd := exec.Command("pandoc", "--from", "markdown", "--to", "ipynb")
+	cmd.Stdin = &doc
+	cmd.Stdout = w
+	return cmd.Run()
+}
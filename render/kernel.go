@@ -0,0 +1,91 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package render
+
+import (
+	"strings"
+
+	"github.com/agarmu/datamine-scraper/model"
+	"github.com/agarmu/datamine-scraper/notebook"
+)
+
+// ProjectLanguage returns the language the kernel of a project's notebook
+// should run: R if most of the page's code listings are in R, Python
+// otherwise. SQL and shell listings count for neither, as those are run
+// through cell magics.
+func ProjectLanguage(p *model.Project) model.Language {
+	counts := model.LanguageCounts(p.Questions)
+	if counts[model.LanguageR] > counts[model.LanguagePython] {
+		return model.LanguageR
+	}
+	return model.LanguagePython
+}
+
+// questionLanguage returns SQL or bash for a question whose listings are
+// mostly in that language, and "" for any other question.
+func questionLanguage(q model.Question) model.Language {
+	switch l := model.DetectLanguage([]model.Question{q}); l {
+	case model.LanguageSQL, model.LanguageBash:
+		return l
+	}
+	return ""
+}
+
+// Kernelspec returns the kernelspec for the configured kernel, or for the
+// language of the project if none is configured.
+func Kernelspec(p *model.Project, opts Options) notebook.Kernelspec {
+	lang := opts.Language
+	if lang == "" {
+		lang = ProjectLanguage(p)
+	}
+	return kernelspecFor(opts.Kernel, lang)
+}
+
+// kernelspecFor returns the kernelspec of the named kernel. Kernels other
+// than python3 and ir are assumed to run lang, unless their name ends in
+// "-r" (as in "f2022-s2023-r"). Without a name, the default kernel of lang
+// is used.
+func kernelspecFor(name string, lang model.Language) notebook.Kernelspec {
+	switch name {
+	case "":
+		if lang == model.LanguageR {
+			return notebook.RKernelspec
+		}
+		return notebook.DefaultKernelspec
+	case notebook.DefaultKernelspec.Name:
+		return notebook.DefaultKernelspec
+	case notebook.RKernelspec.Name:
+		return notebook.RKernelspec
+	}
+	language := notebook.DefaultKernelspec.Language
+	if lang == model.LanguageR || strings.HasSuffix(strings.ToLower(name), "-r") {
+		language = notebook.RKernelspec.Language
+	}
+	return notebook.Kernelspec{
+		DisplayName: name,
+		Language:    language,
+		Name:        name,
+	}
+}
+
+// kernelLanguage is the language the kernel of a project's notebook runs.
+func kernelLanguage(p *model.Project, opts Options) model.Language {
+	if strings.EqualFold(Kernelspec(p, opts).Language, notebook.RKernelspec.Language) {
+		return model.LanguageR
+	}
+	return model.LanguagePython
+}
@@ -0,0 +1,125 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/agarmu/datamine-scraper/model"
+	"github.com/agarmu/datamine-scraper/notebook"
+)
+
+func init() {
+	Register(Format{Name: "py:percent", Extension: ".py", Renderer: Percent{Extension: ".py", Language: model.LanguagePython}})
+	Register(Format{Name: "r:percent", Extension: ".R", Renderer: Percent{Extension: ".R", Language: model.LanguageR}})
+}
+
+// Percent writes scripts in the Jupytext percent format: every cell starts
+// with a "# %%" line and Markdown cells are commented out. The cell roles
+// and the provenance block are kept as metadata Jupytext understands, so
+// converting a script back with `jupytext --to ipynb` gives the notebook the
+// Native renderer would have written.
+type Percent struct {
+	Extension string
+	Language  model.Language // the language of the script, whatever the page's
+}
+
+func (r Percent) Render(w io.Writer, p *model.Project, opts Options) error {
+	opts.Language = r.Language
+	// a script can only be run by a kernel for its own language
+	if lang := kernelLanguage(p, opts); lang != r.Language {
+		return fmt.Errorf("kernel %s runs %s, so it cannot run a %s script", opts.Kernel, kernelspecFor(opts.Kernel, lang).Language, r.Extension)
+	}
+	cells, err := Cells(p, opts)
+	if err != nil {
+		return err
+	}
+	kernelspec := Kernelspec(p, opts)
+	provenance, err := json.Marshal(Provenance(p, opts, "jupytext"))
+	if err != nil {
+		return err
+	}
+	var doc bytes.Buffer
+	// the header is YAML; JSON strings and objects are valid YAML
+	fmt.Fprintf(&doc, `# ---
+# jupyter:
+#   jupytext:
+#     text_representation:
+#       extension: %s
+#       format_name: percent
+#       format_version: '1.3'
+#   kernelspec:
+#     display_name: %s
+#     language: %s
+#     name: %s
+#   tdmscrape: %s
+# ---
+`, r.Extension, jsonString(kernelspec.DisplayName), jsonString(kernelspec.Language), jsonString(kernelspec.Name), provenance)
+	for _, c := range cells {
+		marker := "# %%"
+		if c.Type == notebook.MarkdownCell {
+			marker += " [markdown]"
+		}
+		if tag, ok := c.Metadata["tdmscrape"]; ok {
+			data, err := json.Marshal(tag)
+			if err != nil {
+				return err
+			}
+			marker += " tdmscrape=" + string(data)
+		}
+		source := c.Source
+		if c.Type == notebook.MarkdownCell {
+			source = commentOut(source)
+		} else {
+			source = commentMagics(source)
+		}
+		fmt.Fprintf(&doc, "\n%s\n%s\n", marker, source)
+	}
+	_, err = w.Write(doc.Bytes())
+	return err
+}
+
+// commentOut turns Markdown into script comments the way Jupytext does.
+func commentOut(source string) string {
+	lines := strings.Split(source, "\n")
+	for i, l := range lines {
+		if l == "" {
+			lines[i] = "#"
+		} else {
+			lines[i] = "# " + l
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+var magicLinePattern = regexp.MustCompile(`(?m)^%`)
+
+// commentMagics comments out Jupyter magics the way Jupytext does, so that
+// scripts stay valid code.
+func commentMagics(source string) string {
+	return magicLinePattern.ReplaceAllString(source, "# %")
+}
+
+func jsonString(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
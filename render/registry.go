@@ -0,0 +1,72 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package render
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Format is an output format a project can be rendered in.
+type Format struct {
+	Name      string // as given to --format, e.g. "ipynb"
+	Extension string // of files in this format, e.g. ".ipynb"
+	Renderer  Renderer
+}
+
+var formats = map[string]Format{}
+
+// Register makes a format available under its name. It panics if the name is
+// already taken.
+func Register(f Format) {
+	name := strings.ToLower(f.Name)
+	if _, dup := formats[name]; dup {
+		panic(fmt.Sprintf("render: format %q registered twice", f.Name))
+	}
+	formats[name] = f
+}
+
+// Lookup returns the format with the given name, ignoring case.
+func Lookup(name string) (Format, bool) {
+	f, ok := formats[strings.ToLower(name)]
+	return f, ok
+}
+
+// ForPath returns the format whose files have the extension of path.
+func ForPath(path string) (Format, bool) {
+	ext := filepath.Ext(path)
+	for _, f := range Formats() {
+		if ext != "" && strings.EqualFold(ext, f.Extension) {
+			return f, true
+		}
+	}
+	return Format{}, false
+}
+
+// Formats returns every registered format, sorted by name.
+func Formats() []Format {
+	l := []Format{}
+	for _, f := range formats {
+		l = append(l, f)
+	}
+	sort.Slice(l, func(i, j int) bool {
+		return l[i].Name < l[j].Name
+	})
+	return l
+}
@@ -0,0 +1,115 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// Package render lays a scraped project out as a notebook skeleton and
+// writes it in one of the supported output formats.
+package render
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+
+	"github.com/agarmu/datamine-scraper/model"
+	"github.com/agarmu/datamine-scraper/notebook"
+)
+
+// Options are the details that go into a skeleton besides the questions.
+type Options struct {
+	// Format is the name of the output format, recorded in the provenance
+	// metadata.
+	Format        string
+	Name          string
+	ProjectNumber int
+	// Course and Term are those of the project, e.g. "10100" and "2023",
+	// if known.
+	Course string
+	Term   string
+	// SubSubQuestionsOwnBlocks gives every item below a subquestion its own
+	// prompt and answer cells instead of listing it in its parent's prompt.
+	SubSubQuestionsOwnBlocks bool
+	// Kernel is the name of the Jupyter kernel to use, empty for the default
+	// kernel of the language the project is written in.
+	Kernel string
+	// Language is the language of the kernel, empty to detect it from the
+	// project page.
+	Language      model.Language
+	TAHelp        []string
+	Collaborators []string
+	// Starters pre-fills the setup and answer cells with code for the
+	// languages and packages used on the project page.
+	Starters bool
+	// CheckDatasets adds a setup cell that checks that the datasets of the
+	// project can be found.
+	CheckDatasets bool
+	// Images says what becomes of the images in the questions; empty is
+	// ImagesSkip. ImageDir is where ImagesLink expects the image files,
+	// relative to the output.
+	Images   ImageMode
+	ImageDir string
+	// Template is the text/template source of the skeleton, empty for
+	// DefaultTemplate, and TemplatePath the file it was read from.
+	Template     string
+	TemplatePath string
+	// ToolVersion and ToolCommit identify the program in the provenance
+	// metadata of the output.
+	ToolVersion string
+	ToolCommit  string
+	// knitr makes starters for R Markdown and Quarto documents, which
+	// knitr runs without any cell magics.
+	knitr bool
+}
+
+// A Renderer writes the skeleton of a project in some output format.
+type Renderer interface {
+	Render(w io.Writer, p *model.Project, opts Options) error
+}
+
+// Provenance records where a skeleton came from and how it was laid out.
+func Provenance(p *model.Project, opts Options, writer string) *notebook.ScrapeMetadata {
+	images := opts.Images
+	if images == "" {
+		images = ImagesSkip
+	}
+	return &notebook.ScrapeMetadata{
+		SourceURL:   p.URL,
+		ScrapedAt:   p.ScrapedAt.UTC(),
+		ToolVersion: opts.ToolVersion,
+		ToolCommit:  opts.ToolCommit,
+		ContentHash: model.Hash(p.Questions),
+		Options: notebook.GenerationOptions{
+			SubSubQuestionsOwnBlocks: opts.SubSubQuestionsOwnBlocks,
+			Writer:                   writer,
+			Format:                   opts.Format,
+			Kernel:                   Kernelspec(p, opts).Name,
+			Template:                 opts.TemplatePath,
+			TemplateHash:             templateHash(opts.Template),
+			Starters:                 opts.Starters,
+			Images:                   string(images),
+			CheckDatasets:            opts.CheckDatasets,
+		},
+	}
+}
+
+// templateHash identifies a custom template by its contents.
+func templateHash(source string) string {
+	if source == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(source))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
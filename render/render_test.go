@@ -0,0 +1,150 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/agarmu/datamine-scraper/model"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+// cellIDPattern matches the cell ids of a notebook, which are random.
+var cellIDPattern = regexp.MustCompile(`"id": "[0-9a-f]+"`)
+
+// testProject is the project scraped from the saved page in the testdata of
+// package scrape.
+func testProject(t *testing.T) *model.Project {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "project.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p model.Project
+	if err := json.Unmarshal(data, &p); err != nil {
+		t.Fatal(err)
+	}
+	return &p
+}
+
+func testOptions(format string) Options {
+	return Options{
+		Format:        format,
+		Name:          "Ada Student",
+		ProjectNumber: 1,
+		Course:        "10100",
+		Term:          "2023",
+		TAHelp:        []string{"John Smith"},
+		Collaborators: []string{"Friend1"},
+		ToolVersion:   "v1.0.0",
+		ToolCommit:    "abc1234",
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		golden string
+		format string
+		modify func(*Options)
+	}{
+		{golden: "project.ipynb", format: "ipynb"},
+		{golden: "project-own-blocks.ipynb", format: "ipynb", modify: func(o *Options) {
+			o.SubSubQuestionsOwnBlocks = true
+		}},
+		{golden: "project-starters.ipynb", format: "ipynb", modify: func(o *Options) {
+			o.Starters = true
+			o.CheckDatasets = true
+		}},
+		{golden: "project.Rmd", format: "rmd"},
+		{golden: "project-starters.qmd", format: "qmd", modify: func(o *Options) {
+			o.Starters = true
+		}},
+		{golden: "project-python-starters.qmd", format: "qmd", modify: func(o *Options) {
+			o.Kernel = "python3"
+			o.Starters = true
+		}},
+		{golden: "project.py", format: "py:percent"},
+		{golden: "project.R", format: "r:percent"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			f, ok := Lookup(tt.format)
+			if !ok {
+				t.Fatalf("no format %q", tt.format)
+			}
+			opts := testOptions(tt.format)
+			if tt.modify != nil {
+				tt.modify(&opts)
+			}
+			var out bytes.Buffer
+			if err := f.Renderer.Render(&out, testProject(t), opts); err != nil {
+				t.Fatal(err)
+			}
+			got := cellIDPattern.ReplaceAll(out.Bytes(), []byte(`"id": "0"`))
+			path := filepath.Join("testdata", tt.golden)
+			if *update {
+				if err := os.WriteFile(path, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s differs:\n%s", path, got)
+			}
+		})
+	}
+}
+
+func TestProvenance(t *testing.T) {
+	opts := testOptions("ipynb")
+	opts.Kernel = "f2023-s2024"
+	opts.Starters = true
+	opts.Images = ImagesEmbed
+	opts.Template = DefaultTemplate
+	opts.TemplatePath = "skeleton.tmpl"
+	got := Provenance(testProject(t), opts, "native").Options
+	if got.Format != "ipynb" || got.Kernel != "f2023-s2024" || !got.Starters ||
+		got.Images != string(ImagesEmbed) || got.Template != "skeleton.tmpl" || got.TemplateHash == "" {
+		t.Errorf("options not all recorded: %+v", got)
+	}
+}
+
+// A script cannot be run by a kernel for another language.
+func TestPercentKernel(t *testing.T) {
+	f, _ := Lookup("py:percent")
+	opts := testOptions("py:percent")
+	opts.Kernel = "ir"
+	if err := f.Renderer.Render(io.Discard, testProject(t), opts); err == nil {
+		t.Error("rendered a .py script for the ir kernel")
+	}
+	opts.Kernel = "f2023-s2024"
+	if err := f.Renderer.Render(io.Discard, testProject(t), opts); err != nil {
+		t.Errorf("kernel f2023-s2024: %v", err)
+	}
+}
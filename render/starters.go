@@ -0,0 +1,187 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package render
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/agarmu/datamine-scraper/model"
+)
+
+// pythonPackage is a package commonly used in projects, with the way it is
+// conventionally imported.
+type pythonPackage struct {
+	name      string
+	alias     string // e.g. "pd" for pandas, empty if imported under its own name
+	statement string
+}
+
+var pythonPackages = []pythonPackage{
+	{"pandas", "pd", "import pandas as pd"},
+	{"numpy", "np", "import numpy as np"},
+	{"matplotlib", "plt", "import matplotlib.pyplot as plt"},
+	{"seaborn", "sns", "import seaborn as sns"},
+	{"plotly", "px", "import plotly.express as px"},
+	{"polars", "pl", "import polars as pl"},
+	{"sqlite3", "", "import sqlite3"},
+	{"requests", "", "import requests"},
+}
+
+// rPackages are R packages commonly used in projects.
+var rPackages = []string{"tidyverse", "dplyr", "ggplot2", "data.table", "lubridate", "stringr", "readr", "tidyr", "DBI", "RSQLite"}
+
+var (
+	pythonImportPattern = regexp.MustCompile(`(?m)^\s*((?:import|from\s+\S+\s+import)\s+[^#\n]*?)\s*$`)
+	rLibraryPattern     = regexp.MustCompile(`\b(?:library|require)\(\s*["']?([\w.]+)["']?\s*\)`)
+	databasePattern     = regexp.MustCompile(`(?:sqlite:///)?(/?[\w./-]+\.(?:db|sqlite3?))\b`)
+)
+
+// connectionName is the variable holding the database connection of the
+// starters, in R or in Python.
+const connectionName = "con"
+
+// starters is the code --starters pre-fills a skeleton with.
+type starters struct {
+	imports    string                    // imports or library() calls for the packages used on the page
+	connection string                    // connects to the database of SQL questions, if there are any
+	answer     map[model.Language]string // the start of answer cells in each language
+}
+
+// projectStarters works out the starter code for a project whose notebook
+// runs a kernel for lang. SQL and shell answers start with a cell magic in
+// Python; the IRkernel has no magics, so they are left empty in R. Neither
+// has knitr: it runs SQL chunks on a DBI connection made in R, and in
+// documents in Python the SQL answers query a sqlite3 connection instead.
+func projectStarters(p *model.Project, lang model.Language, items []TemplateItem, knitr bool) starters {
+	var s starters
+	var listings, mentions []string
+	walkListings(p.Questions, func(b model.Block) {
+		if l := model.ListingLanguage(b); l == lang || l == "" {
+			listings = append(listings, b.Content)
+		}
+	}, func(text string) {
+		mentions = append(mentions, text)
+	})
+	code := strings.Join(listings, "\n")
+	prose := strings.Join(mentions, "\n")
+	if lang == model.LanguageR {
+		s.imports = rLibraries(code, prose)
+	} else {
+		s.imports = pythonImports(code, prose)
+	}
+	if usesLanguage(items, model.LanguageSQL) {
+		database := "path/to/database.db"
+		if m := databasePattern.FindStringSubmatch(code + "\n" + prose); m != nil {
+			database = m[1]
+		}
+		switch {
+		case lang == model.LanguageR:
+			s.connection = fmt.Sprintf("library(RSQLite)\n%s <- dbConnect(SQLite(), %q)", connectionName, database)
+		case knitr:
+			s.connection = fmt.Sprintf("import sqlite3\n%s = sqlite3.connect(%q)", connectionName, database)
+			s.answer = map[model.Language]string{
+				model.LanguageSQL: connectionName + ".execute(\"\"\"\n\n\"\"\").fetchall()",
+			}
+		default:
+			s.connection = "%load_ext sql\n%sql sqlite:///" + database
+		}
+	}
+	if lang != model.LanguageR && !knitr {
+		s.answer = map[model.Language]string{
+			model.LanguageSQL:  "%%sql",
+			model.LanguageBash: "%%bash",
+		}
+	}
+	return s
+}
+
+// walkListings calls listing for every code listing in the questions, and
+// text for every other piece of text.
+func walkListings(qs []model.Question, listing func(model.Block), text func(string)) {
+	for _, q := range qs {
+		text(q.Text)
+		for _, b := range q.Body {
+			if b.Kind == model.BlockListing {
+				listing(b)
+			} else {
+				text(b.Content)
+			}
+		}
+		walkListings(q.Children, listing, text)
+	}
+}
+
+func usesLanguage(items []TemplateItem, lang model.Language) bool {
+	for _, item := range items {
+		if item.Language == lang || usesLanguage(item.Items, lang) {
+			return true
+		}
+	}
+	return false
+}
+
+// inlineCode matches a name written as inline code, in Markdown or HTML.
+func inlineCode(name string) *regexp.Regexp {
+	return regexp.MustCompile("(?:`|<code>)" + regexp.QuoteMeta(name) + "(?:`|</code>)")
+}
+
+// pythonImports collects the import statements of the page's listings, and
+// adds the conventional import of every well-known package that is used
+// through its alias in a listing or mentioned as inline code.
+func pythonImports(code string, prose string) string {
+	var lines []string
+	seen := map[string]bool{}
+	for _, m := range pythonImportPattern.FindAllStringSubmatch(code, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			lines = append(lines, m[1])
+		}
+	}
+	for _, pkg := range pythonPackages {
+		imported := regexp.MustCompile(`\b` + regexp.QuoteMeta(pkg.name) + `\b`).MatchString(strings.Join(lines, "\n"))
+		used := inlineCode(pkg.name).MatchString(prose) ||
+			pkg.alias != "" && regexp.MustCompile(`\b`+pkg.alias+`\.\w`).MatchString(code)
+		if used && !imported {
+			lines = append(lines, pkg.statement)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// rLibraries collects the packages loaded in the page's listings and the
+// well-known packages mentioned as inline code, as library() calls.
+func rLibraries(code string, prose string) string {
+	var lines []string
+	seen := map[string]bool{}
+	add := func(pkg string) {
+		if !seen[pkg] {
+			seen[pkg] = true
+			lines = append(lines, "library("+pkg+")")
+		}
+	}
+	for _, m := range rLibraryPattern.FindAllStringSubmatch(code+"\n"+prose, -1) {
+		add(m[1])
+	}
+	for _, pkg := range rPackages {
+		if inlineCode(pkg).MatchString(prose) {
+			add(pkg)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,271 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package render
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/agarmu/datamine-scraper/internal/markdown"
+	"github.com/agarmu/datamine-scraper/model"
+	"github.com/agarmu/datamine-scraper/notebook"
+)
+
+// DefaultTemplate is the skeleton used unless Options.Template is set.
+//
+//go:embed skeleton.tmpl
+var DefaultTemplate string
+
+// TemplateData is what a skeleton template is executed with.
+type TemplateData struct {
+	Options
+	Title       string // "Project N -- Name"
+	PageTitle   string // the <h1> of the project page
+	URL         string
+	Attribution string
+	Language    model.Language // the language of the notebook's kernel
+	Questions   []TemplateItem
+	// With Options.Starters, Imports imports the packages used on the page
+	// and Connection connects to the database of SQL questions. Both are
+	// empty otherwise.
+	Imports    string
+	Connection string
+	// Datasets are the paths of all the datasets used in the project, and
+	// DatasetCheck is the code checking for them with Options.CheckDatasets.
+	Datasets     []string
+	DatasetCheck string
+}
+
+// TemplateItem is a question, or an item of one of its lists, together with
+// its place in the tree.
+type TemplateItem struct {
+	Key    string // tags the cells of the item, e.g. "Question 2/B/iii"
+	Label  string // e.g. "B" or "iii", empty for questions
+	Depth  int    // 0 for questions, 1 for subquestions, and so on
+	Index  int    // position among its siblings, from 0
+	Header string
+	Text   string
+	Body   []model.Block
+	Items  []TemplateItem
+	// Language is the language answers are written in: SQL or bash for
+	// questions that clearly call for it, else that of its parent.
+	Language model.Language
+	// Starter is the code answers start with, e.g. a %%sql magic. It is
+	// only set with Options.Starters.
+	Starter string
+	// Datasets are the datasets the item refers to, not counting its items.
+	Datasets []string
+	// OwnCells is set when the items of this item should get cells of their
+	// own rather than being listed in its prompt.
+	OwnCells bool
+}
+
+// templateFuncs are the helpers available to skeleton templates.
+var templateFuncs = template.FuncMap{
+	"markdown": func(role string, key ...string) string { return cellBoundary(notebook.MarkdownCell, role, key) },
+	"code":     func(role string, key ...string) string { return cellBoundary(notebook.CodeCell, role, key) },
+	"letter":   toLetters,
+	"roman":    toRoman,
+	"label":    itemLabel,
+	"render":   renderBlock,
+	"quote":    markdown.Quote,
+	"join":     strings.Join,
+	"repeat":   strings.Repeat,
+	"lower":    strings.ToLower,
+	"upper":    strings.ToUpper,
+	"add":      func(a, b int) int { return a + b },
+	"sub":      func(a, b int) int { return a - b },
+}
+
+// Cell boundaries are written to the template output as
+// RS kind US role US key RS, which cannot occur in scraped text.
+const (
+	boundaryMark = "\x1e"
+	fieldMark    = "\x1f"
+)
+
+var boundaryPattern = regexp.MustCompile("\x1e([^\x1e\x1f]*)\x1f([^\x1e\x1f]*)\x1f([^\x1e\x1f]*)\x1e")
+
+func cellBoundary(kind notebook.CellType, role string, key []string) string {
+	return boundaryMark + string(kind) + fieldMark + role + fieldMark + strings.Join(key, "/") + boundaryMark
+}
+
+// ParseTemplate checks a skeleton template, so that mistakes in it can be
+// reported before any work is done.
+func ParseTemplate(source string) (*template.Template, error) {
+	return template.New("skeleton").Funcs(templateFuncs).Parse(source)
+}
+
+// Cells lays out the notebook skeleton for the scraped questions by running
+// the template. Every cell is tagged with its role, and cells belonging to a
+// question with its key.
+func Cells(p *model.Project, opts Options) ([]notebook.Cell, error) {
+	cells, _, err := layout(p, opts)
+	return cells, err
+}
+
+// layout does the work of Cells, also returning what the template was
+// executed with.
+func layout(p *model.Project, opts Options) ([]notebook.Cell, TemplateData, error) {
+	source := opts.Template
+	if source == "" {
+		source = DefaultTemplate
+	}
+	tmpl, err := ParseTemplate(source)
+	if err != nil {
+		return nil, TemplateData{}, err
+	}
+	data := TemplateData{
+		Options:     opts,
+		Title:       title(opts),
+		PageTitle:   p.Title,
+		URL:         p.URL,
+		Attribution: attribution(p),
+		Language:    kernelLanguage(p, opts),
+		Datasets:    model.Datasets(p.Questions),
+	}
+	for i, q := range p.Questions {
+		item, err := templateItem(q, notebook.QuestionKey(q.Header), 0, i, data.Language, opts)
+		if err != nil {
+			return nil, data, err
+		}
+		data.Questions = append(data.Questions, item)
+	}
+	if opts.Starters {
+		s := projectStarters(p, data.Language, data.Questions, opts.knitr)
+		data.Imports, data.Connection = s.imports, s.connection
+		setStarters(data.Questions, s)
+	}
+	if opts.CheckDatasets {
+		data.DatasetCheck = datasetCheck(data.Datasets, data.Language)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return nil, data, err
+	}
+	cells, err := splitCells(out.String())
+	if err != nil {
+		return nil, data, err
+	}
+	if opts.Images == ImagesLink || opts.Images == ImagesEmbed {
+		if err := placeImages(cells, p, opts); err != nil {
+			return nil, data, err
+		}
+	}
+	// code cells of questions in another language than the kernel's say so
+	languages := map[string]model.Language{}
+	itemLanguages(data.Questions, data.Language, languages)
+	for i, c := range cells {
+		role, key := notebook.CellRole(c)
+		if l, ok := languages[key]; ok && c.Type == notebook.CodeCell && role != "" {
+			cells[i] = notebook.WithLanguage(c, string(l))
+		}
+	}
+	return cells, data, nil
+}
+
+func templateItem(q model.Question, key string, depth int, index int, lang model.Language, opts Options) (TemplateItem, error) {
+	if l := questionLanguage(q); l != "" {
+		lang = l
+	}
+	item := TemplateItem{
+		Key:      key,
+		Depth:    depth,
+		Index:    index,
+		Header:   q.Header,
+		Text:     q.Text,
+		Body:     q.Body,
+		Language: lang,
+		Datasets: q.Datasets,
+		OwnCells: depth > 0 && len(q.Children) > 0 && opts.SubSubQuestionsOwnBlocks,
+	}
+	if depth > 0 {
+		var err error
+		item.Label, err = itemLabel(depth, index)
+		if err != nil {
+			return item, err
+		}
+	}
+	for i, c := range q.Children {
+		label, err := itemLabel(depth+1, i)
+		if err != nil {
+			return item, err
+		}
+		child, err := templateItem(c, notebook.SubKey(key, label), depth+1, i, lang, opts)
+		if err != nil {
+			return item, err
+		}
+		item.Items = append(item.Items, child)
+	}
+	return item, nil
+}
+
+func setStarters(items []TemplateItem, s starters) {
+	for i := range items {
+		items[i].Starter = s.answer[items[i].Language]
+		setStarters(items[i].Items, s)
+	}
+}
+
+// itemLanguages records the keys of the items whose language differs from
+// that of the kernel.
+func itemLanguages(items []TemplateItem, kernel model.Language, languages map[string]model.Language) {
+	for _, item := range items {
+		if item.Language != kernel {
+			languages[item.Key] = item.Language
+		}
+		itemLanguages(item.Items, kernel, languages)
+	}
+}
+
+// splitCells cuts the template output into cells at the boundaries.
+func splitCells(out string) ([]notebook.Cell, error) {
+	bounds := boundaryPattern.FindAllStringSubmatchIndex(out, -1)
+	if len(bounds) == 0 {
+		return nil, fmt.Errorf("template produced no cells")
+	}
+	if text := strings.TrimSpace(out[:bounds[0][0]]); text != "" {
+		return nil, fmt.Errorf("template output %q comes before the first cell", text)
+	}
+	cells := []notebook.Cell{}
+	for i, b := range bounds {
+		end := len(out)
+		if i+1 < len(bounds) {
+			end = bounds[i+1][0]
+		}
+		kind, role, key := out[b[2]:b[3]], out[b[4]:b[5]], out[b[6]:b[7]]
+		source := strings.Trim(out[b[1]:end], "\n")
+		var c notebook.Cell
+		switch notebook.CellType(kind) {
+		case notebook.MarkdownCell:
+			c = notebook.NewMarkdownCell(source)
+		case notebook.CodeCell:
+			c = notebook.NewCodeCell(source)
+		default:
+			return nil, fmt.Errorf("unknown cell type %q", kind)
+		}
+		if role != "" {
+			c = notebook.WithRole(c, role, key)
+		}
+		cells = append(cells, c)
+	}
+	return cells, nil
+}
@@ -0,0 +1,98 @@
+# ---
+# jupyter:
+#   jupytext:
+#     text_representation:
+#       extension: .py
+#       format_name: percent
+#       format_version: '1.3'
+#   kernelspec:
+#     display_name: "Python 3 (ipykernel)"
+#     language: "python"
+#     name: "python3"
+#   tdmscrape: {"source_url":"https://the-examples-book.com/projects/current-projects/10100-2023-project01","scraped_at":"2023-08-21T12:00:00Z","tool_version":"v1.0.0","tool_commit":"abc1234","content_hash":"sha256:8adbe70738de4857c167aba3da947dcc0b438a3cdec8f0ea491500c782dd1a44","options":{"sub_sub_questions_own_blocks":false,"writer":"jupytext","format":"py:percent","kernel":"python3","starters":false,"images":"skip","check_datasets":false}}
+# ---
+
+# %% [markdown] tdmscrape={"role":"title"}
+# # Project 1 -- Ada Student
+#
+# _This skeleton for this file was generated by [the TDM Scraper made by Mukul Agarwal](https://github.com/agarmu/datamine-scraper) from the contents of [this url](https://the-examples-book.com/projects/current-projects/10100-2023-project01)._
+
+# %% [markdown] tdmscrape={"role":"collaboration"}
+# **TA Help:** John Smith
+#
+# - Help with figuring out how to write a function.
+#
+# **Collaboration:** Friend1
+#
+# - Helped figuring out how to load the dataset.
+# - Helped debug error with my plot.
+
+# %% [markdown] tdmscrape={"role":"datasets"}
+# ## Datasets
+#
+# This project uses the following datasets:
+#
+# - `/anvil/projects/tdm/data/flights/subset/1990.csv`
+
+# %% tdmscrape={"role":"setup"}
+
+
+# %% [markdown] tdmscrape={"key":"Question 1","role":"prompt"}
+# ## Question 1 (2 pts)
+#
+# **Load the data with `read.csv`.**
+#
+# ```r
+# dat <- read.csv("/anvil/projects/tdm/data/flights/subset/1990.csv")
+# head(dat)
+# ```
+#
+# > **Tip:** See [the read.csv page](https://the-examples-book.com/projects/book/r/read.csv.html).
+
+# %% [markdown] tdmscrape={"key":"Question 1/A","role":"prompt"}
+# **A. How many rows are there?**
+
+# %% tdmscrape={"key":"Question 1/A","role":"answer"}
+
+
+# %% [markdown] tdmscrape={"key":"Question 1/A","role":"notes"}
+# Markdown notes and sentences and analysis written here.
+
+# %% [markdown] tdmscrape={"key":"Question 1/B","role":"prompt"}
+# **B. Plot the departure delays.**
+#
+# ![delays](https://the-examples-book.com/projects/current-projects/images/delays.png)
+#
+# *i. Label the axes.*<br/>
+# *ii. Add a _title_.*<br/>
+
+# %% tdmscrape={"key":"Question 1/B","role":"answer"}
+
+
+# %% [markdown] tdmscrape={"key":"Question 1/B","role":"notes"}
+# Markdown notes and sentences and analysis written here.
+
+# %% [markdown] tdmscrape={"key":"Question 2","role":"prompt"}
+# ## Question 2 (3 pts)
+#
+# **Important:** use the `flights` table from the database below.
+#
+# ```sql
+# SELECT * FROM flights LIMIT 5;
+# ```
+
+# %% [markdown] tdmscrape={"key":"Question 2/A","role":"prompt"}
+# **A. Which airline has the most flights?**
+
+# %% tdmscrape={"key":"Question 2/A","language":"sql","role":"answer"}
+
+
+# %% [markdown] tdmscrape={"key":"Question 2/A","role":"notes"}
+# Markdown notes and sentences and analysis written here.
+
+# %% [markdown] tdmscrape={"role":"pledge"}
+# ## Pledge
+#
+# By submitting this work I hereby pledge that this is my own, personal work. I've acknowledged in the designated place at the top of this file all sources that I used to complete said work, including but not limited to: online resources, books, and electronic communications. I've noted all collaboration with fellow students and/or TA's. I did not copy or plagiarize another's work.
+#
+# > As a Boilermaker pursuing academic excellence, I pledge to be honest and true in all that I do. Accountable together – We are Purdue.
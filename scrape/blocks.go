@@ -0,0 +1,124 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package scrape
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/agarmu/datamine-scraper/model"
+)
+
+// blockClasses maps the wrapper classes Asciidoctor puts on each block to
+// the kind of block.
+var blockClasses = []struct {
+	class string
+	kind  model.BlockKind
+}{
+	{"paragraph", model.BlockParagraph},
+	{"listingblock", model.BlockListing},
+	{"literalblock", model.BlockLiteral},
+	{"admonitionblock", model.BlockAdmonition},
+	{"imageblock", model.BlockImage},
+	{"tableblock", model.BlockTable},
+	{"olist", model.BlockList},
+	{"ulist", model.BlockList},
+	{"dlist", model.BlockList},
+	{"colist", model.BlockList},
+	{"hdlist", model.BlockList},
+	{"quoteblock", model.BlockQuote},
+	{"verseblock", model.BlockQuote},
+	{"exampleblock", model.BlockExample},
+	{"sidebarblock", model.BlockSidebar},
+	{"openblock", model.BlockExample},
+	{"videoblock", model.BlockVideo},
+}
+
+func blockKind(s *goquery.Selection) (model.BlockKind, bool) {
+	if s.Is("p") {
+		// bare paragraphs show up inside list items
+		return model.BlockParagraph, true
+	}
+	for _, c := range blockClasses {
+		if s.HasClass(c.class) {
+			return c.kind, true
+		}
+	}
+	return "", false
+}
+
+// parseBlocks turns the children of a question section into blocks, in page
+// order. The header and any element for which skip returns true are left out.
+func (p *parser) parseBlocks(question string, children *goquery.Selection, skip func(*goquery.Selection) bool) []model.Block {
+	blocks := []model.Block{}
+	children.Each(func(_ int, s *goquery.Selection) {
+		if s.Is("h1, h2, h3, h4, h5, h6") || skip(s) {
+			return
+		}
+		kind, ok := blockKind(s)
+		if !ok {
+			p.addError(question, s, "unrecognized block kept as HTML")
+			kind = model.BlockHTML
+		}
+		b := model.Block{
+			Kind:  kind,
+			Title: strings.TrimSpace(s.ChildrenFiltered(".title").Text()),
+		}
+		content := s.Find(".content").First()
+		switch kind {
+		case model.BlockParagraph:
+			para := s
+			if !s.Is("p") {
+				para = s.Find("p").First()
+			}
+			b.Content = p.innerHTML(question, para)
+		case model.BlockListing, model.BlockLiteral:
+			pre := s.Find("pre").First()
+			b.Content = strings.TrimRight(pre.Text(), "\n")
+			b.Language = pre.Find("code").AttrOr("data-lang", "")
+			if b.Language == "" {
+				b.Language = pre.AttrOr("data-lang", "")
+			}
+		case model.BlockAdmonition:
+			b.Label = strings.TrimSpace(s.Find("td.icon .title").Text())
+			if b.Label == "" {
+				b.Label = strings.TrimSpace(s.Find("td.icon i").AttrOr("title", ""))
+			}
+			b.Content = p.innerHTML(question, s.Find("td.content").First())
+		case model.BlockImage:
+			b.Content = p.outerHTML(question, s.Find("img").First())
+		case model.BlockTable:
+			b.Content = p.outerHTML(question, s.Filter("table").AddSelection(s.Find("table")).First())
+		case model.BlockList:
+			b.Content = p.outerHTML(question, s.ChildrenFiltered("ol, ul, dl, table").First())
+		case model.BlockVideo:
+			src := s.Find("iframe, video").AttrOr("src", "")
+			b.Content = fmt.Sprintf(`<a href="%s">%s</a>`, src, src)
+		case model.BlockHTML:
+			b.Content = p.outerHTML(question, s)
+		default:
+			if content.Length() == 0 {
+				content = s
+			}
+			b.Content = p.innerHTML(question, content)
+		}
+		b.Content = strings.TrimSpace(b.Content)
+		blocks = append(blocks, b)
+	})
+	return blocks
+}
@@ -0,0 +1,271 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package scrape
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/agarmu/datamine-scraper/internal/fileutil"
+)
+
+// CacheEntry describes a page stored in the on-disk cache. The body itself
+// lives next to it in a file with the same key.
+type CacheEntry struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	ContentType  string    `json:"content_type,omitempty"`
+	Fetched      time.Time `json:"fetched"`
+	Size         int       `json:"size"`
+}
+
+// ErrNotCached is returned for pages that are not in the cache.
+var ErrNotCached = errors.New("page is not in the cache")
+
+// CacheDir returns the directory holding cached pages, without creating it.
+func CacheDir() (string, error) {
+	return cacheSubdir("pages")
+}
+
+// imageCacheDir returns the directory holding cached images, which are kept
+// apart from the pages.
+func imageCacheDir() (string, error) {
+	return cacheSubdir("images")
+}
+
+func cacheSubdir(name string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "tdmscrape", name), nil
+}
+
+func cacheKey(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:])
+}
+
+func cachePaths(cacheDir func() (string, error), url string) (meta string, body string, err error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return "", "", err
+	}
+	key := cacheKey(url)
+	return filepath.Join(dir, key+".json"), filepath.Join(dir, key+".body"), nil
+}
+
+func loadCacheEntry(cacheDir func() (string, error), url string) (CacheEntry, []byte, error) {
+	var entry CacheEntry
+	metaPath, bodyPath, err := cachePaths(cacheDir, url)
+	if err != nil {
+		return entry, nil, err
+	}
+	meta, err := os.ReadFile(metaPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return entry, nil, ErrNotCached
+	} else if err != nil {
+		return entry, nil, err
+	}
+	if err := json.Unmarshal(meta, &entry); err != nil {
+		return entry, nil, err
+	}
+	body, err := os.ReadFile(bodyPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return entry, nil, ErrNotCached
+	}
+	return entry, body, err
+}
+
+func storeCacheEntry(cacheDir func() (string, error), entry CacheEntry, body []byte) error {
+	metaPath, bodyPath, err := cachePaths(cacheDir, entry.URL)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(metaPath), 0755); err != nil {
+		return err
+	}
+	meta, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	// write the body first so that a metadata file never points at nothing;
+	// scrapers running at the same time never read a half-written entry
+	if err := fileutil.WriteAtomic(bodyPath, body); err != nil {
+		return err
+	}
+	return fileutil.WriteAtomic(metaPath, meta)
+}
+
+// ListCache returns every cached page, most recently fetched first.
+func ListCache() ([]CacheEntry, error) {
+	dir, err := CacheDir()
+	if err != nil {
+		return nil, err
+	}
+	files, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	entries := []CacheEntry{}
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var entry CacheEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return nil, fmt.Errorf("corrupt cache entry %s: %w", f.Name(), err)
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Fetched.After(entries[j].Fetched)
+	})
+	return entries, nil
+}
+
+// RemoveFromCache removes a single page from the cache.
+func RemoveFromCache(url string) error {
+	metaPath, bodyPath, err := cachePaths(CacheDir, url)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(metaPath); errors.Is(err, os.ErrNotExist) {
+		return ErrNotCached
+	} else if err != nil {
+		return err
+	}
+	return os.Remove(bodyPath)
+}
+
+// ClearCache removes every cached page, and the images cached along with
+// them.
+func ClearCache() error {
+	for _, cacheDir := range []func() (string, error){CacheDir, imageCacheDir} {
+		dir, err := cacheDir()
+		if err != nil {
+			return err
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cachingTransport serves GET requests from the on-disk cache in the
+// directory returned by dir, revalidating them with conditional requests
+// unless offline is set. Responses that cannot be stored are passed to warn
+// and served all the same.
+type cachingTransport struct {
+	next    http.RoundTripper
+	dir     func() (string, error)
+	offline bool
+	warn    func(error)
+}
+
+func (t *cachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return t.next.RoundTrip(req)
+	}
+	url := req.URL.String()
+	entry, body, err := loadCacheEntry(t.dir, url)
+	cached := err == nil
+	if err != nil && !errors.Is(err, ErrNotCached) {
+		return nil, err
+	}
+	if t.offline {
+		if !cached {
+			return nil, fmt.Errorf("%w (offline mode)", ErrNotCached)
+		}
+		return cachedResponse(req, entry, body), nil
+	}
+	if cached {
+		req = req.Clone(req.Context())
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if cached && resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		return cachedResponse(req, entry, body), nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+	body, err = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	entry = CacheEntry{
+		URL:          url,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		ContentType:  resp.Header.Get("Content-Type"),
+		Fetched:      time.Now(),
+		Size:         len(body),
+	}
+	if err := storeCacheEntry(t.dir, entry, body); err != nil {
+		t.warn(fmt.Errorf("could not cache %s: %w", url, err))
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+func cachedResponse(req *http.Request, entry CacheEntry, body []byte) *http.Response {
+	header := http.Header{}
+	if entry.ContentType != "" {
+		header.Set("Content-Type", entry.ContentType)
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
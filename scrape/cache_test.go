@@ -0,0 +1,125 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package scrape
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// testCache points the user cache directory at a temporary one.
+func testCache(t *testing.T) {
+	t.Helper()
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+}
+
+func body(t *testing.T, rt http.RoundTripper, url string) (string, error) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	return string(data), err
+}
+
+func TestCachingTransport(t *testing.T) {
+	testCache(t)
+	page := "version 1"
+	var fetches, notModified atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		etag := `"` + page + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		io.WriteString(w, page)
+	}))
+	defer server.Close()
+	online := &cachingTransport{next: http.DefaultTransport, dir: CacheDir, warn: func(err error) { t.Error(err) }}
+	offline := &cachingTransport{next: http.DefaultTransport, dir: CacheDir, offline: true, warn: func(err error) { t.Error(err) }}
+
+	if _, err := body(t, offline, server.URL); !errors.Is(err, ErrNotCached) {
+		t.Errorf("offline before caching: got %v, want %v", err, ErrNotCached)
+	}
+	steps := []struct {
+		name        string
+		rt          http.RoundTripper
+		page        string
+		want        string
+		fetches     int32
+		notModified int32
+	}{
+		{"first fetch", online, "version 1", "version 1", 1, 0},
+		{"revalidated", online, "version 1", "version 1", 2, 1},
+		{"offline", offline, "version 1", "version 1", 2, 1},
+		{"changed", online, "version 2", "version 2", 3, 1},
+		{"offline after change", offline, "version 2", "version 2", 3, 1},
+	}
+	for _, s := range steps {
+		page = s.page
+		got, err := body(t, s.rt, server.URL)
+		if err != nil {
+			t.Fatalf("%s: %v", s.name, err)
+		}
+		if got != s.want || fetches.Load() != s.fetches || notModified.Load() != s.notModified {
+			t.Errorf("%s: got %q after %d fetches (%d not modified), want %q after %d (%d)",
+				s.name, got, fetches.Load(), notModified.Load(), s.want, s.fetches, s.notModified)
+		}
+	}
+
+	entries, err := ListCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].URL != server.URL || entries[0].ETag != `"version 2"` {
+		t.Errorf("got cache entries %+v", entries)
+	}
+	if err := RemoveFromCache(entries[0].URL); err != nil {
+		t.Fatal(err)
+	}
+	if err := RemoveFromCache(entries[0].URL); !errors.Is(err, ErrNotCached) {
+		t.Errorf("removing twice: got %v, want %v", err, ErrNotCached)
+	}
+}
+
+// Pages that are not there are not cached.
+func TestCachingTransportError(t *testing.T) {
+	testCache(t)
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	rt := &cachingTransport{next: http.DefaultTransport, dir: CacheDir, warn: func(err error) { t.Error(err) }}
+	if _, err := body(t, rt, server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := ListCache(); len(entries) != 0 {
+		t.Errorf("cached a 404: %+v", entries)
+	}
+}
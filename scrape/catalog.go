@@ -0,0 +1,101 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package scrape
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/agarmu/datamine-scraper/model"
+	"github.com/gocolly/colly"
+)
+
+// DefaultIndexURL is where Catalog starts looking for project pages.
+const DefaultIndexURL = "https://the-examples-book.com/projects/"
+
+// CatalogEntry is a project page found by Catalog.
+type CatalogEntry struct {
+	model.ProjectID `yaml:",inline"`
+	Title           string `json:"title" yaml:"title"`
+	URL             string `json:"url" yaml:"url"`
+}
+
+// Catalog crawls the index pages below indexURL and returns the project
+// pages they link to, ordered by course, term and number. Index pages are
+// followed one level deep; project pages themselves are not fetched, their
+// course, term and number come from their slug.
+func (s *Scraper) Catalog(ctx context.Context, indexURL string) ([]CatalogEntry, error) {
+	index, err := url.ParseRequestURI(indexURL)
+	if err != nil {
+		return nil, err
+	}
+	prefix := index.Path
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		prefix = prefix[:i+1]
+	}
+	c := s.collector(ctx, colly.MaxDepth(2))
+	found := map[string]CatalogEntry{}
+	var errs []error
+	c.OnError(func(r *colly.Response, err error) {
+		errs = append(errs, err)
+	})
+	c.OnHTML("a[href]", func(a *colly.HTMLElement) {
+		u, err := url.Parse(a.Request.AbsoluteURL(a.Attr("href")))
+		if err != nil || u.Host != index.Host || !strings.HasPrefix(u.Path, prefix) {
+			return
+		}
+		u.Fragment = ""
+		if id, ok := model.ParseSlug(u.Path); ok {
+			if _, seen := found[u.String()]; !seen {
+				found[u.String()] = CatalogEntry{ProjectID: id, Title: strings.TrimSpace(a.Text), URL: u.String()}
+			}
+			return
+		}
+		// already visited pages and those too deep are no error here
+		a.Request.Visit(u.String())
+	})
+	if err := c.Visit(index.String()); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(found) == 0 && len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	entries := make([]CatalogEntry, 0, len(found))
+	for _, e := range found {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Course != b.Course {
+			return a.Course < b.Course
+		}
+		if a.Term != b.Term {
+			return a.Term < b.Term
+		}
+		if a.Number != b.Number {
+			return a.Number < b.Number
+		}
+		return a.URL < b.URL
+	})
+	return entries, nil
+}
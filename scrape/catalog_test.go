@@ -0,0 +1,94 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package scrape
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/agarmu/datamine-scraper/model"
+)
+
+func TestCatalog(t *testing.T) {
+	var mu sync.Mutex
+	visited := map[string]bool{}
+	pages := map[string]string{
+		"/projects/": `<a href="current-projects/">Current projects</a>
+			<a href="/projects/10100-2023-project02">Project 2</a>
+			<a href="/projects/10100-2023-project02#questions">Project 2 again</a>
+			<a href="/elsewhere/40100-2023-project01">Not an index below this one</a>
+			<a href="https://example.com/projects/10200-2023-project01">Another site</a>`,
+		"/projects/current-projects/": `<a href="10100-2023-project01"> Project 1 </a>
+			<a href="20200-2022-project-03">Project 3</a>
+			<a href="archive/">Archive</a>
+			<a href="/projects/">Back</a>`,
+		"/projects/current-projects/archive/": `<a href="30100-2021-project01">Too deep</a>`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		visited[r.URL.Path] = true
+		mu.Unlock()
+		page, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "<html><body>%s</body></html>", page)
+	}))
+	defer server.Close()
+
+	s := New(Options{NoCache: true, Parallelism: 4})
+	got, err := s.Catalog(context.Background(), server.URL+"/projects/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []CatalogEntry{
+		{ProjectID: model.ProjectID{Course: "10100", Term: "2023", Number: 1}, Title: "Project 1", URL: server.URL + "/projects/current-projects/10100-2023-project01"},
+		{ProjectID: model.ProjectID{Course: "10100", Term: "2023", Number: 2}, Title: "Project 2", URL: server.URL + "/projects/10100-2023-project02"},
+		{ProjectID: model.ProjectID{Course: "20200", Term: "2022", Number: 3}, Title: "Project 3", URL: server.URL + "/projects/current-projects/20200-2022-project-03"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+	// project pages are told apart by their slug, not fetched
+	for path := range visited {
+		if _, ok := model.ParseSlug(path); ok {
+			t.Errorf("fetched the project page %s", path)
+		}
+	}
+	if visited["/projects/current-projects/archive/"] {
+		t.Error("followed the index pages more than one level deep")
+	}
+}
+
+func TestCatalogError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	s := New(Options{NoCache: true})
+	if _, err := s.Catalog(context.Background(), server.URL+"/projects/"); err == nil {
+		t.Error("got no error for a missing index page")
+	}
+	if _, err := s.Catalog(context.Background(), "projects"); err == nil {
+		t.Error("got no error for a relative url")
+	}
+}
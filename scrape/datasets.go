@@ -0,0 +1,52 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package scrape
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/agarmu/datamine-scraper/model"
+)
+
+// datasetPattern matches the paths of data files on Anvil, globs included.
+var datasetPattern = regexp.MustCompile(`/anvil/projects/tdm/data/[^\s"'<>()\[\]{},;]*`)
+
+// findDatasets records the datasets every question refers to in its text,
+// listings or any other block, in the order they appear.
+func findDatasets(qs []model.Question) {
+	for i := range qs {
+		q := &qs[i]
+		q.Datasets = nil
+		seen := map[string]bool{}
+		add := func(text string) {
+			for _, path := range datasetPattern.FindAllString(text, -1) {
+				// a path ending a sentence
+				path = strings.TrimRight(path, ".:")
+				if !seen[path] {
+					seen[path] = true
+					q.Datasets = append(q.Datasets, path)
+				}
+			}
+		}
+		add(q.Text)
+		for _, b := range q.Body {
+			add(b.Content)
+		}
+		findDatasets(q.Children)
+	}
+}
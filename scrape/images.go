@@ -0,0 +1,151 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package scrape
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/agarmu/datamine-scraper/internal/markdown"
+	"github.com/agarmu/datamine-scraper/model"
+)
+
+// FetchImages downloads the images shown in the questions of a project into
+// project.Images, resolving their urls against the page and going through
+// a cache of their own. Images that could not be downloaded are left out and
+// reported together in the returned error.
+func (s *Scraper) FetchImages(ctx context.Context, project *model.Project) error {
+	base, err := url.Parse(project.URL)
+	if err != nil {
+		return err
+	}
+	var sources []string
+	seen := map[string]bool{}
+	model.WalkHTML(project.Questions, func(text *string) error {
+		markdown.ReplaceImages(*text, func(src string) string {
+			if !seen[src] && !strings.HasPrefix(src, "data:") {
+				seen[src] = true
+				sources = append(sources, src)
+			}
+			return src
+		})
+		return nil
+	})
+	client := &http.Client{Transport: s.transport(ctx, imageCacheDir)}
+	names := map[string]bool{}
+	var errs []error
+	project.Images = nil
+	for _, src := range sources {
+		ref, err := url.Parse(src)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("image %s: %w", src, err))
+			continue
+		}
+		u := base.ResolveReference(ref)
+		image, err := fetchImage(client, u, base.Scheme == "file")
+		if err != nil {
+			errs = append(errs, fmt.Errorf("image %s: %w", u, err))
+			continue
+		}
+		image.Source = src
+		image.Name = uniqueName(imageName(u, image.MediaType), names)
+		project.Images = append(project.Images, image)
+	}
+	return errors.Join(errs...)
+}
+
+// fetchImage downloads an image, or reads it from disk if local is set,
+// for pages that were read from a file. A page from the web may not pull
+// in local files.
+func fetchImage(client *http.Client, u *url.URL, local bool) (model.Image, error) {
+	var image model.Image
+	var err error
+	contentType := ""
+	if u.Scheme == "file" {
+		if !local {
+			return image, errors.New("a page from the web cannot show local files")
+		}
+		image.Data, err = os.ReadFile(u.Path)
+	} else {
+		var resp *http.Response
+		resp, err = client.Get(u.String())
+		if err != nil {
+			return image, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return image, errors.New(resp.Status)
+		}
+		contentType = resp.Header.Get("Content-Type")
+		image.Data, err = io.ReadAll(resp.Body)
+	}
+	if err != nil {
+		return image, err
+	}
+	image.MediaType = imageType(contentType, mime.TypeByExtension(path.Ext(u.Path)), http.DetectContentType(image.Data))
+	if image.MediaType == "" {
+		return image, errors.New("not an image")
+	}
+	return image, nil
+}
+
+// imageType returns the first of the content types that is an image type,
+// without parameters, or "" if there is none.
+func imageType(contentTypes ...string) string {
+	for _, t := range contentTypes {
+		if t, _, err := mime.ParseMediaType(t); err == nil && strings.HasPrefix(t, "image/") {
+			return t
+		}
+	}
+	return ""
+}
+
+var unsafeNameChars = regexp.MustCompile(`[^\w.-]+`)
+
+// imageName makes a file name for an image from its url.
+func imageName(u *url.URL, mediaType string) string {
+	name := unsafeNameChars.ReplaceAllString(path.Base(u.Path), "_")
+	if name == "" || name == "." || name == "_" {
+		name = "image"
+	}
+	if path.Ext(name) == "" {
+		if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+			name += exts[0]
+		}
+	}
+	return name
+}
+
+// uniqueName numbers name if it has been taken already.
+func uniqueName(name string, taken map[string]bool) string {
+	ext := path.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	for i := 2; taken[name]; i++ {
+		name = fmt.Sprintf("%s-%d%s", stem, i, ext)
+	}
+	taken[name] = true
+	return name
+}
@@ -0,0 +1,65 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package scrape
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/agarmu/datamine-scraper/model"
+)
+
+// png is the start of a PNG file, enough for its type to be detected.
+var png = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+// Images are cached apart from the pages, which the cache lists.
+func TestFetchImagesCache(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(png)
+	}))
+	defer server.Close()
+	project := &model.Project{
+		URL:       server.URL + "/projects/project01",
+		Questions: []model.Question{{Text: "![plot](images/plot.png)"}},
+	}
+	s := New(Options{IgnoreRobots: true})
+	if err := s.FetchImages(context.Background(), project); err != nil {
+		t.Fatal(err)
+	}
+	if len(project.Images) != 1 || project.Images[0].MediaType != "image/png" {
+		t.Fatalf("got images %+v, want one PNG", project.Images)
+	}
+	pages, err := ListCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 0 {
+		t.Errorf("the image is listed as a cached page: %+v", pages)
+	}
+	dir, err := imageCacheDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := os.ReadDir(dir); len(files) == 0 {
+		t.Error("the image was not cached")
+	}
+}
@@ -0,0 +1,206 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package scrape
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/agarmu/datamine-scraper/model"
+)
+
+// ParseError describes a part of a project page that did not have the
+// expected structure. Scraping carries on past it with a best-effort guess,
+// so a page yields its questions together with a list of these.
+type ParseError struct {
+	Question string // header of the enclosing question
+	Path     string // DOM path of the offending element
+	Snippet  string // beginning of the element's text
+	Reason   string
+}
+
+func (e *ParseError) Error() string {
+	msg := e.Reason
+	if e.Question != "" {
+		msg = e.Question + ": " + msg
+	}
+	if e.Path != "" {
+		msg += " at " + e.Path
+	}
+	if e.Snippet != "" {
+		msg += fmt.Sprintf(" (%q)", e.Snippet)
+	}
+	return msg
+}
+
+// ParseErrors is returned by a Strict scrape that found any problems.
+type ParseErrors []*ParseError
+
+func (e ParseErrors) Error() string {
+	return fmt.Sprintf("%d problem(s) found on the project page", len(e))
+}
+
+// parser collects the problems found while parsing one page.
+type parser struct {
+	errs ParseErrors
+}
+
+func (p *parser) addError(question string, s *goquery.Selection, format string, args ...interface{}) {
+	p.errs = append(p.errs, &ParseError{
+		Question: question,
+		Path:     domPath(s),
+		Snippet:  snippet(s, 60),
+		Reason:   fmt.Sprintf(format, args...),
+	})
+}
+
+// domPath renders a selector-like path to the first element of s, from the
+// enclosing question section down, e.g. "div.sect2 > div.olist > ol > li:nth-child(2)".
+func domPath(s *goquery.Selection) string {
+	if s.Length() == 0 {
+		return ""
+	}
+	parts := []string{}
+	for n := s.First(); n.Length() > 0 && !n.Is("body, html"); n = n.Parent() {
+		part := goquery.NodeName(n)
+		if class, ok := n.Attr("class"); ok && strings.TrimSpace(class) != "" {
+			part += "." + strings.Fields(class)[0]
+		}
+		if goquery.NodeName(n) == "li" {
+			part += fmt.Sprintf(":nth-child(%d)", n.Index()+1)
+		}
+		parts = append([]string{part}, parts...)
+		if n.HasClass("sect2") {
+			break
+		}
+	}
+	return strings.Join(parts, " > ")
+}
+
+func snippet(s *goquery.Selection, length int) string {
+	text := strings.Join(strings.Fields(s.First().Text()), " ")
+	if len([]rune(text)) > length {
+		text = string([]rune(text)[:length]) + "…"
+	}
+	return text
+}
+
+// parseQuestion extracts a question from a `.sect2` section of a project
+// page. Sections that are not questions are reported with ok == false.
+func (p *parser) parseQuestion(question *goquery.Selection) (model.Question, bool) {
+	// inside question area
+	q := model.Question{
+		Header:   "",
+		Text:     "",
+		Body:     []model.Block{},
+		Children: []model.Question{},
+	}
+	q.Header = strings.TrimSpace(question.Find("h3").First().Text())
+	if !strings.Contains(q.Header, "Question") {
+		// not a question, exit
+		return q, false
+	}
+	// get the description, if it exists: a paragraph that is all bold. Bold
+	// text at the start of a longer paragraph stays part of the body.
+	desc := question.Find(".paragraph strong").First()
+	descParagraph := desc.Closest(".paragraph")
+	if desc.Length() > 0 && strings.TrimSpace(descParagraph.Text()) == strings.TrimSpace(desc.Text()) {
+		q.Text = strings.TrimSpace(p.innerHTML(q.Header, desc))
+	} else {
+		descParagraph = nil
+	}
+	// get the subquestions, if they exist; a list directly inside the
+	// section is preferred over one nested in e.g. an admonition
+	subquestionList := question.ChildrenFiltered(".olist").First()
+	if subquestionList.Length() < 1 {
+		subquestionList = question.ChildrenFiltered(".ulist").First()
+	}
+	if subquestionList.Length() < 1 {
+		subquestionList = question.Find(".olist").First()
+	}
+	if subquestionList.Length() < 1 {
+		subquestionList = question.Find(".ulist").First()
+	}
+	// everything else in the section is the body of the question; the
+	// description is shown on its own
+	q.Body = p.parseBlocks(q.Header, question.Children(), func(s *goquery.Selection) bool {
+		return s.IsSelection(subquestionList) || descParagraph != nil && s.IsSelection(descParagraph)
+	})
+	if subquestionList.Length() > 0 {
+		q.Children = p.parseItems(q.Header, subquestionList)
+	}
+	return q, true
+}
+
+// parseItems turns an `.olist`/`.ulist` block into question nodes, following
+// nested lists to any depth.
+func (p *parser) parseItems(question string, list *goquery.Selection) []model.Question {
+	items := []model.Question{}
+	list.ChildrenFiltered("ol, ul").First().ChildrenFiltered("li").Each(func(_ int, li *goquery.Selection) {
+		text, textElement := p.itemText(question, li)
+		nested := li.ChildrenFiltered(".olist, .ulist")
+		item := model.Question{
+			Text: text,
+			Body: p.parseBlocks(question, li.Children(), func(s *goquery.Selection) bool {
+				return s.IsSelection(textElement) || s.IsSelection(nested)
+			}),
+			Children: []model.Question{},
+		}
+		nested.Each(func(_ int, s *goquery.Selection) {
+			item.Children = append(item.Children, p.parseItems(question, s)...)
+		})
+		items = append(items, item)
+	})
+	return items
+}
+
+// innerHTML returns the HTML inside s, falling back to its plain text.
+func (p *parser) innerHTML(question string, s *goquery.Selection) string {
+	html, err := s.Html()
+	if err != nil {
+		p.addError(question, s, "could not extract HTML: %v", err)
+		return s.Text()
+	}
+	return html
+}
+
+func (p *parser) outerHTML(question string, s *goquery.Selection) string {
+	html, err := goquery.OuterHtml(s)
+	if err != nil {
+		p.addError(question, s, "could not extract HTML: %v", err)
+		return s.Text()
+	}
+	return html
+}
+
+// itemText returns the text of a list item along with the element it was
+// taken from. The text is normally a leading <p>, sometimes wrapped in a
+// paragraph <div>; other shapes are reported and handled as well as possible.
+func (p *parser) itemText(question string, li *goquery.Selection) (string, *goquery.Selection) {
+	first := li.ChildrenFiltered("p, .paragraph").First()
+	if first.Is("p") {
+		return strings.TrimSpace(p.innerHTML(question, first)), first
+	}
+	if para := first.ChildrenFiltered("p").First(); para.Length() > 0 {
+		return strings.TrimSpace(p.innerHTML(question, para)), first
+	}
+	p.addError(question, li, "list item has no paragraph")
+	bare := li.Clone()
+	bare.Children().Remove()
+	return strings.TrimSpace(bare.Text()), first
+}
@@ -0,0 +1,143 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package scrape
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	md "github.com/JohannesKaufmann/html-to-markdown"
+	"github.com/JohannesKaufmann/html-to-markdown/plugin"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/agarmu/datamine-scraper/model"
+)
+
+// A Stage is one step of the pipeline that turns the HTML fragments scraped
+// from a project page into the Markdown written to the notebook.
+type Stage struct {
+	Name  string
+	Apply func(text string) (string, error)
+}
+
+// RewriteRule is a user-defined regular expression replacement, applied to
+// the Markdown after conversion. Replace may refer to groups as $1 etc.
+type RewriteRule struct {
+	Pattern string `yaml:"pattern"`
+	Replace string `yaml:"replace"`
+}
+
+// DefaultPipeline returns the stages applied to every question: links are
+// made absolute against the page url while still HTML, then converted to
+// Markdown, tidied up, and finally run through the user's rewrite rules.
+func DefaultPipeline(base *url.URL, rules []RewriteRule) ([]Stage, error) {
+	stages := []Stage{
+		absolutizeLinks(base),
+		htmlToMarkdown(),
+		normalizeWhitespace(),
+	}
+	for i, r := range rules {
+		stage, err := rewriteStage(r)
+		if err != nil {
+			return nil, fmt.Errorf("rewrite rule %d: %w", i+1, err)
+		}
+		stages = append(stages, stage)
+	}
+	return stages, nil
+}
+
+// RunPipeline applies the stages, in order, to the question tree.
+func RunPipeline(qs []model.Question, stages []Stage) error {
+	return model.WalkHTML(qs, func(text *string) error {
+		for _, s := range stages {
+			out, err := s.Apply(*text)
+			if err != nil {
+				return fmt.Errorf("%s: %w", s.Name, err)
+			}
+			*text = out
+		}
+		return nil
+	})
+}
+
+func absolutizeLinks(base *url.URL) Stage {
+	return Stage{
+		Name: "absolutize links",
+		Apply: func(text string) (string, error) {
+			// a saved page without a url of its own has a file:// one
+			if base == nil || base.Host == "" && base.Scheme != "file" || !strings.Contains(text, "<") {
+				return text, nil
+			}
+			doc, err := goquery.NewDocumentFromReader(strings.NewReader(text))
+			if err != nil {
+				return "", err
+			}
+			resolve := func(attr string) func(int, *goquery.Selection) {
+				return func(_ int, s *goquery.Selection) {
+					ref, err := url.Parse(s.AttrOr(attr, ""))
+					if err != nil {
+						// leave links we cannot make sense of alone
+						return
+					}
+					s.SetAttr(attr, base.ResolveReference(ref).String())
+				}
+			}
+			doc.Find("a[href]").Each(resolve("href"))
+			doc.Find("img[src]").Each(resolve("src"))
+			return doc.Find("body").Html()
+		},
+	}
+}
+
+func htmlToMarkdown() Stage {
+	converter := md.NewConverter("", true, nil)
+	converter.Use(plugin.GitHubFlavored())
+	return Stage{
+		Name:  "html to markdown",
+		Apply: converter.ConvertString,
+	}
+}
+
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+func normalizeWhitespace() Stage {
+	return Stage{
+		Name: "normalize whitespace",
+		Apply: func(text string) (string, error) {
+			lines := strings.Split(text, "\n")
+			for i, l := range lines {
+				lines[i] = strings.TrimRight(l, " \t")
+			}
+			text = strings.Join(lines, "\n")
+			return strings.TrimSpace(blankLines.ReplaceAllString(text, "\n\n")), nil
+		},
+	}
+}
+
+func rewriteStage(rule RewriteRule) (Stage, error) {
+	re, err := regexp.Compile(rule.Pattern)
+	if err != nil {
+		return Stage{}, err
+	}
+	return Stage{
+		Name: "rewrite " + rule.Pattern,
+		Apply: func(text string) (string, error) {
+			return re.ReplaceAllString(text, rule.Replace), nil
+		},
+	}, nil
+}
@@ -0,0 +1,197 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package scrape
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/agarmu/datamine-scraper/model"
+)
+
+func TestAbsolutizeLinks(t *testing.T) {
+	base, _ := url.Parse("https://the-examples-book.com/projects/current-projects/10100-2023-project01")
+	stage := absolutizeLinks(base)
+	tests := []struct {
+		name string
+		in   string
+		want []string
+	}{
+		{
+			name: "relative link",
+			in:   `See <a href="../datasets.html">the datasets</a>.`,
+			want: []string{`href="https://the-examples-book.com/projects/datasets.html"`},
+		},
+		{
+			name: "image",
+			in:   `<img src="images/plot.png"/>`,
+			want: []string{`src="https://the-examples-book.com/projects/current-projects/images/plot.png"`},
+		},
+		{
+			name: "absolute link",
+			in:   `<a href="https://example.com/x">x</a>`,
+			want: []string{`href="https://example.com/x"`},
+		},
+		{
+			name: "plain text",
+			in:   "no markup here",
+			want: []string{"no markup here"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := stage.Apply(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(got, w) {
+					t.Errorf("got %q, want it to contain %q", got, w)
+				}
+			}
+		})
+	}
+}
+
+func TestAbsolutizeLinksWithoutBase(t *testing.T) {
+	in := `<a href="x.html">x</a>`
+	got, err := absolutizeLinks(&url.URL{}).Apply(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != in {
+		t.Errorf("got %q, want the text unchanged", got)
+	}
+}
+
+func TestAbsolutizeLinksFileBase(t *testing.T) {
+	base := &url.URL{Scheme: "file", Path: "/home/student/saved/project01.html"}
+	got, err := absolutizeLinks(base).Apply(`<img src="project01_files/plot.png"/>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `src="file:///home/student/saved/project01_files/plot.png"`; !strings.Contains(got, want) {
+		t.Errorf("got %q, want it to contain %q", got, want)
+	}
+}
+
+func TestHTMLToMarkdown(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"<strong>Load the data.</strong>", "**Load the data.**"},
+		{"Use <code>read.csv</code>.", "Use `read.csv`."},
+		{`<a href="https://example.com/">a link</a>`, "[a link](https://example.com/)"},
+		{"<ul><li>one</li><li>two</li></ul>", "- one\n- two"},
+	}
+	stage := htmlToMarkdown()
+	for _, tt := range tests {
+		got, err := stage.Apply(tt.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeWhitespace(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"  text  ", "text"},
+		{"one  \ntwo\t", "one\ntwo"},
+		{"one\n\n\n\ntwo", "one\n\ntwo"},
+		{"\n\none\n\n", "one"},
+	}
+	stage := normalizeWhitespace()
+	for _, tt := range tests {
+		got, err := stage.Apply(tt.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRewriteRules(t *testing.T) {
+	stages, err := DefaultPipeline(nil, []RewriteRule{
+		{Pattern: `/anvil/projects/tdm/data`, Replace: `$$DATA`},
+		{Pattern: `\bTODO\b`, Replace: `FIXME`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	qs := []model.Question{{Text: "Read <code>/anvil/projects/tdm/data/flights.csv</code> TODO"}}
+	if err := RunPipeline(qs, stages); err != nil {
+		t.Fatal(err)
+	}
+	if want := "Read `$DATA/flights.csv` FIXME"; qs[0].Text != want {
+		t.Errorf("got %q, want %q", qs[0].Text, want)
+	}
+}
+
+func TestBadRewriteRule(t *testing.T) {
+	_, err := DefaultPipeline(nil, []RewriteRule{{Pattern: "("}})
+	if err == nil || !strings.Contains(err.Error(), "rewrite rule 1") {
+		t.Errorf("got %v, want an error naming the rule", err)
+	}
+}
+
+func TestRunPipeline(t *testing.T) {
+	base, _ := url.Parse("https://the-examples-book.com/projects/current-projects/10100-2023-project01")
+	stages, err := DefaultPipeline(base, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	qs := []model.Question{{
+		Header: "Question 1",
+		Text:   "<em>Load</em> the data.",
+		Body: []model.Block{
+			{Kind: model.BlockParagraph, Content: `See <a href="notes.html">the notes</a>.`},
+			{Kind: model.BlockListing, Language: "python", Content: "x = <b>1</b>"},
+		},
+		Children: []model.Question{
+			{Text: "Plot <code>x</code>.", Children: []model.Question{{Text: "<strong>Label</strong> it."}}},
+		},
+	}}
+	if err := RunPipeline(qs, stages); err != nil {
+		t.Fatal(err)
+	}
+	q := qs[0]
+	checks := []struct {
+		name, got, want string
+	}{
+		{"description", q.Text, "_Load_ the data."},
+		{"paragraph", q.Body[0].Content, "See [the notes](https://the-examples-book.com/projects/current-projects/notes.html)."},
+		{"listing", q.Body[1].Content, "x = <b>1</b>"},
+		{"subquestion", q.Children[0].Text, "Plot `x`."},
+		{"sub-subquestion", q.Children[0].Children[0].Text, "**Label** it."},
+		{"header", q.Header, "Question 1"},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, c.got, c.want)
+		}
+	}
+}
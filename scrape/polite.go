@@ -0,0 +1,309 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package scrape
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gocolly/colly"
+	"github.com/temoto/robotstxt"
+)
+
+// DefaultUserAgent identifies the requests of a Scraper whose options name
+// no other user agent.
+const DefaultUserAgent = "tdmscrape (+https://github.com/agarmu/datamine-scraper)"
+
+// retryBackoff is the wait before the first retry of a failed request; it
+// doubles with every further attempt.
+const retryBackoff = 500 * time.Millisecond
+
+// maxRetryAfter is the longest Retry-After a request waits out before
+// retrying; a server asking for more gets its error passed on.
+const maxRetryAfter = time.Minute
+
+// DefaultParallelism is how many requests a Scraper has in flight to one
+// host when its options do not say.
+const DefaultParallelism = 2
+
+// ErrBlockedByRobots is returned for requests that robots.txt disallows.
+var ErrBlockedByRobots = errors.New("blocked by robots.txt")
+
+// collector returns a collector for the requests made on behalf of ctx. It
+// identifies itself, and its requests pass the gate of the Scraper like all
+// others.
+func (s *Scraper) collector(ctx context.Context, options ...func(*colly.Collector)) *colly.Collector {
+	c := colly.NewCollector(options...)
+	c.UserAgent = s.userAgent()
+	// the gate reads robots.txt, once for all collectors
+	c.IgnoreRobotsTxt = true
+	c.WithTransport(s.transport(ctx, CacheDir))
+	// the transport times out every attempt on its own
+	c.SetRequestTimeout(0)
+	return c
+}
+
+func (s *Scraper) userAgent() string {
+	if s.opts.UserAgent != "" {
+		return s.opts.UserAgent
+	}
+	return DefaultUserAgent
+}
+
+// gate is shared by all the requests of a Scraper, from any number of
+// goroutines. It allows at most parallelism requests to the same host at a
+// time, starts them at least delay apart and, if robots is set, turns away
+// those that the host's robots.txt disallows, reading every robots.txt once.
+//
+// colly's LimitRule does the same for the requests of one collector. The
+// gate takes its place because a Scraper makes a collector for every call
+// and downloads images without one, and all of those share the limits.
+type gate struct {
+	delay       time.Duration
+	parallelism int
+	robots      bool
+	userAgent   string
+
+	mu    sync.Mutex
+	hosts map[string]*hostGate
+}
+
+type hostGate struct {
+	slots chan struct{} // one per request in flight
+
+	mu   sync.Mutex
+	next time.Time // when the next request may start
+
+	robotsMu sync.Mutex
+	robots   *robotstxt.RobotsData
+}
+
+func newGate(delay time.Duration, parallelism int, robots bool, userAgent string) *gate {
+	if parallelism <= 0 {
+		parallelism = DefaultParallelism
+	}
+	return &gate{delay: delay, parallelism: parallelism, robots: robots, userAgent: userAgent, hosts: map[string]*hostGate{}}
+}
+
+func (g *gate) host(name string) *hostGate {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	h, ok := g.hosts[name]
+	if !ok {
+		h = &hostGate{slots: make(chan struct{}, g.parallelism)}
+		g.hosts[name] = h
+	}
+	return h
+}
+
+// acquire blocks until the host may be sent another request, and books that
+// slot. The returned function gives the slot back once the request is done;
+// it may be called more than once.
+func (h *hostGate) acquire(ctx context.Context, delay time.Duration) (release func(), err error) {
+	select {
+	case h.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	var once sync.Once
+	release = func() {
+		once.Do(func() { <-h.slots })
+	}
+	h.mu.Lock()
+	start := time.Now()
+	if h.next.After(start) {
+		start = h.next
+	}
+	h.next = start.Add(delay)
+	h.mu.Unlock()
+	select {
+	case <-time.After(time.Until(start)):
+		return release, nil
+	case <-ctx.Done():
+		release()
+		return nil, ctx.Err()
+	}
+}
+
+// postpone keeps any request to the host from starting for d, as asked by a
+// Retry-After header.
+func (h *hostGate) postpone(d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if until := time.Now().Add(d); until.After(h.next) {
+		h.next = until
+	}
+}
+
+// politeTransport identifies every request, passes every attempt at one
+// through the gate, gives each attempt at most timeout to complete, and
+// retries those that fail with a network error or a server error, backing
+// off exponentially or as long as the server asks.
+type politeTransport struct {
+	next      http.RoundTripper
+	gate      *gate
+	userAgent string
+	timeout   time.Duration
+	retries   int
+}
+
+func (t *politeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+	h := t.gate.host(req.URL.Host)
+	if t.gate.robots && req.URL.Path != "/robots.txt" {
+		robots, err := t.robots(h, req)
+		if err != nil {
+			return nil, err
+		}
+		if !robots.TestAgent(req.URL.EscapedPath(), t.gate.userAgent) {
+			return nil, ErrBlockedByRobots
+		}
+	}
+	return t.send(h, req)
+}
+
+// robots returns the robots.txt of the host of req, fetching it the first
+// time. A fetch that fails, or that the server answers with an error of its
+// own, is not kept and is tried again with the next request.
+func (t *politeTransport) robots(h *hostGate, req *http.Request) (*robotstxt.RobotsData, error) {
+	h.robotsMu.Lock()
+	defer h.robotsMu.Unlock()
+	if h.robots != nil {
+		return h.robots, nil
+	}
+	robotsURL := req.URL.Scheme + "://" + req.URL.Host + "/robots.txt"
+	robotsReq, err := http.NewRequestWithContext(req.Context(), http.MethodGet, robotsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	robotsReq.Header.Set("User-Agent", req.Header.Get("User-Agent"))
+	resp, err := t.send(h, robotsReq)
+	if err != nil {
+		return nil, fmt.Errorf("could not read robots.txt: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return nil, fmt.Errorf("could not read robots.txt: %s", resp.Status)
+	}
+	robots, err := robotstxt.FromResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+	h.robots = robots
+	return robots, nil
+}
+
+// send makes the attempts at req.
+func (t *politeTransport) send(h *hostGate, req *http.Request) (*http.Response, error) {
+	retries := t.retries
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		retries = 0
+	}
+	for attempt := 0; ; attempt++ {
+		resp, err := t.try(h, req)
+		if attempt >= retries || req.Context().Err() != nil || !retryable(resp, err) {
+			return resp, err
+		}
+		after, ok := retryAfter(resp)
+		if !ok {
+			return resp, err
+		}
+		h.postpone(after)
+		if resp != nil {
+			resp.Body.Close()
+		}
+		select {
+		case <-time.After(retryBackoff << attempt):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// try makes a single attempt at req once the gate lets it. The timeout
+// covers reading the body, which is why it is only lifted, and the gate
+// told, once the body is closed.
+func (t *politeTransport) try(h *hostGate, req *http.Request) (*http.Response, error) {
+	release, err := h.acquire(req.Context(), t.gate.delay)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := req.Context(), context.CancelFunc(func() {})
+	if t.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+	}
+	resp, err := t.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		release()
+		return nil, err
+	}
+	resp.Body = &doneBody{ReadCloser: resp.Body, done: func() {
+		cancel()
+		release()
+	}}
+	return resp, nil
+}
+
+// retryable tells whether a failed attempt may succeed if tried again.
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+}
+
+// retryAfter is how long a 429 or 503 response asks to wait before the
+// next attempt, zero if it does not say. It reports false if that is longer
+// than is worth waiting.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil || resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return 0, true
+	}
+	value := resp.Header.Get("Retry-After")
+	var after time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		after = time.Duration(seconds) * time.Second
+	} else if at, err := http.ParseTime(value); err == nil {
+		after = time.Until(at)
+	}
+	if after < 0 {
+		after = 0
+	}
+	return after, after <= maxRetryAfter
+}
+
+// doneBody calls done once the body is closed.
+type doneBody struct {
+	io.ReadCloser
+	done func()
+}
+
+func (b *doneBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.done()
+	return err
+}
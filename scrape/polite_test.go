@@ -0,0 +1,279 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package scrape
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testTransport sends requests straight to the network through a gate of
+// its own.
+func testTransport(delay time.Duration, parallelism int, robots bool, retries int) *politeTransport {
+	return &politeTransport{
+		next:      http.DefaultTransport,
+		gate:      newGate(delay, parallelism, robots, DefaultUserAgent),
+		userAgent: DefaultUserAgent,
+		retries:   retries,
+	}
+}
+
+func get(t *testing.T, rt http.RoundTripper, url string) (*http.Response, error) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := rt.RoundTrip(req)
+	if err == nil {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	return resp, err
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		status int
+		err    error
+		want   bool
+	}{
+		{err: errors.New("connection reset"), want: true},
+		{status: http.StatusOK, want: false},
+		{status: http.StatusNotFound, want: false},
+		{status: http.StatusTooManyRequests, want: true},
+		{status: http.StatusInternalServerError, want: true},
+		{status: http.StatusServiceUnavailable, want: true},
+	}
+	for _, tt := range tests {
+		var resp *http.Response
+		if tt.err == nil {
+			resp = &http.Response{StatusCode: tt.status}
+		}
+		if got := retryable(resp, tt.err); got != tt.want {
+			t.Errorf("retryable(%d, %v) = %v, want %v", tt.status, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		status int
+		header string
+		want   time.Duration
+		ok     bool
+	}{
+		{http.StatusTooManyRequests, "", 0, true},
+		{http.StatusTooManyRequests, "3", 3 * time.Second, true},
+		{http.StatusServiceUnavailable, "3", 3 * time.Second, true},
+		{http.StatusInternalServerError, "3", 0, true},
+		{http.StatusTooManyRequests, "3600", time.Hour, false},
+		{http.StatusTooManyRequests, "soon", 0, true},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+		resp.Header.Set("Retry-After", tt.header)
+		got, ok := retryAfter(resp)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%d with Retry-After %q: got %v, %v, want %v, %v", tt.status, tt.header, got, ok, tt.want, tt.ok)
+		}
+	}
+	resp := &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{}}
+	resp.Header.Set("Retry-After", time.Now().Add(10*time.Second).UTC().Format(http.TimeFormat))
+	if got, ok := retryAfter(resp); !ok || got < 8*time.Second || got > 10*time.Second {
+		t.Errorf("Retry-After as a date: got %v, %v", got, ok)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+	resp, err := get(t, testTransport(0, 0, false, 3), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || attempts.Load() != 3 {
+		t.Errorf("got %s after %d attempts, want 200 OK after 3", resp.Status, attempts.Load())
+	}
+}
+
+func TestRetriesGiveUp(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	resp, err := get(t, testTransport(0, 0, false, 1), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusInternalServerError || attempts.Load() != 2 {
+		t.Errorf("got %s after %d attempts, want 500 after 2", resp.Status, attempts.Load())
+	}
+}
+
+// A retry waits as long as Retry-After says, and so do the other requests
+// to the host.
+func TestRetryAfterWaits(t *testing.T) {
+	var mu sync.Mutex
+	var starts []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		starts = append(starts, time.Now())
+		first := len(starts) == 1
+		mu.Unlock()
+		if first {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+	rt := testTransport(0, 0, false, 1)
+	if _, err := get(t, rt, server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := get(t, rt, server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if len(starts) != 3 {
+		t.Fatalf("got %d requests, want 3", len(starts))
+	}
+	if wait := starts[1].Sub(starts[0]); wait < time.Second {
+		t.Errorf("retried after %v, want at least a second", wait)
+	}
+}
+
+func TestGateDelay(t *testing.T) {
+	const delay = 100 * time.Millisecond
+	var mu sync.Mutex
+	var starts []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		starts = append(starts, time.Now())
+		mu.Unlock()
+	}))
+	defer server.Close()
+	rt := testTransport(delay, 4, false, 0)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := get(t, rt, server.URL); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	for i := 1; i < len(starts); i++ {
+		// allow for the clock of the timer
+		if gap := starts[i].Sub(starts[i-1]); gap < delay-10*time.Millisecond {
+			t.Errorf("requests %d and %d started %v apart, want %v", i, i+1, gap, delay)
+		}
+	}
+}
+
+func TestGateParallelism(t *testing.T) {
+	var inFlight, most atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := most.Load()
+			if n <= m || most.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer server.Close()
+	rt := testTransport(0, 2, false, 0)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := get(t, rt, server.URL); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if most.Load() > 2 {
+		t.Errorf("%d requests were in flight at once, want at most 2", most.Load())
+	}
+}
+
+func TestRobots(t *testing.T) {
+	var robotsFetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			robotsFetches.Add(1)
+			io.WriteString(w, "User-agent: *\nDisallow: /private\n")
+		}
+	}))
+	defer server.Close()
+	rt := testTransport(0, 0, true, 0)
+	for _, path := range []string{"/a", "/b"} {
+		if _, err := get(t, rt, server.URL+path); err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+	}
+	if _, err := get(t, rt, server.URL+"/private/c"); !errors.Is(err, ErrBlockedByRobots) {
+		t.Errorf("/private/c: got %v, want %v", err, ErrBlockedByRobots)
+	}
+	if n := robotsFetches.Load(); n != 1 {
+		t.Errorf("robots.txt fetched %d times, want once", n)
+	}
+}
+
+// A robots.txt that failed to load does not block the host for good.
+func TestRobotsServerError(t *testing.T) {
+	var robotsFetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" && robotsFetches.Add(1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+	rt := testTransport(0, 0, true, 0)
+	_, err := get(t, rt, server.URL+"/a")
+	if err == nil || errors.Is(err, ErrBlockedByRobots) || !strings.Contains(err.Error(), "robots.txt") {
+		t.Errorf("first request: got %v, want an error reading robots.txt", err)
+	}
+	if _, err := get(t, rt, server.URL+"/a"); err != nil {
+		t.Errorf("second request: %v", err)
+	}
+	if n := robotsFetches.Load(); n != 2 {
+		t.Errorf("robots.txt fetched %d times, want twice", n)
+	}
+}
@@ -0,0 +1,282 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// Package scrape fetches Data Mine project pages and extracts the questions
+// on them.
+package scrape
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/agarmu/datamine-scraper/model"
+	"github.com/gocolly/colly"
+)
+
+// Options control how a Scraper fetches and parses pages.
+type Options struct {
+	// BaseURL is the url of a page read from a file or stdin, used to
+	// resolve its relative links. It overrides the page's canonical link.
+	BaseURL string
+	// Offline only uses pages from the cache, never the network.
+	Offline bool
+	// NoCache neither reads nor writes the page cache.
+	NoCache bool
+	// Strict fails a scrape that found any unexpected page structure,
+	// returning the problems as ParseErrors.
+	Strict bool
+	// KeepHTML leaves question text as HTML instead of running it through
+	// the Markdown pipeline.
+	KeepHTML bool
+	// RewriteRules are applied to the Markdown after conversion.
+	RewriteRules []RewriteRule
+	// Warn is called with every problem found on a page when not Strict, as
+	// a *ParseError, and with problems that did not stop a scrape, such as a
+	// page that could not be cached.
+	Warn func(error)
+	// Timeout bounds every attempt at a request, zero meaning no limit.
+	Timeout time.Duration
+	// Retries is how many times a request that failed with a network or
+	// server error is tried again.
+	Retries int
+	// Delay is the time waited between the starts of two requests to the
+	// same host.
+	Delay time.Duration
+	// Parallelism is how many requests to the same host may be in flight at
+	// a time; zero means DefaultParallelism.
+	Parallelism int
+	// UserAgent identifies the requests; empty means DefaultUserAgent.
+	UserAgent string
+	// IgnoreRobots fetches pages even where robots.txt disallows it.
+	IgnoreRobots bool
+}
+
+// Scraper extracts projects from pages. One Scraper may be used for any
+// number of pages, concurrently; its requests share the Delay and
+// Parallelism limits of a host and the robots.txt read from it.
+type Scraper struct {
+	opts Options
+	gate *gate
+}
+
+func New(opts Options) *Scraper {
+	s := &Scraper{opts: opts}
+	// offline there is no robots.txt to fetch, and nothing is fetched anyway
+	s.gate = newGate(opts.Delay, opts.Parallelism, !opts.IgnoreRobots && !opts.Offline, s.userAgent())
+	return s
+}
+
+// WithWarn returns a Scraper that passes its problems to warn instead, and
+// shares the requests' gate with s.
+func (s *Scraper) WithWarn(warn func(error)) *Scraper {
+	c := *s
+	c.opts.Warn = warn
+	return &c
+}
+
+// SourceKind tells the kinds of source a project can be scraped from apart.
+type SourceKind int
+
+const (
+	SourceURL SourceKind = iota
+	SourceFile
+	SourceStdin
+)
+
+// Classify works out whether source names stdin ("-"), a saved page on disk,
+// or a page on the web.
+func Classify(source string) (SourceKind, error) {
+	if source == "-" {
+		return SourceStdin, nil
+	}
+	if info, err := os.Stat(source); err == nil {
+		if info.IsDir() {
+			return 0, fmt.Errorf("%s is a directory", source)
+		}
+		return SourceFile, nil
+	}
+	u, err := url.ParseRequestURI(source)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return 0, fmt.Errorf("%s is neither an existing file nor a URL", source)
+	}
+	return SourceURL, nil
+}
+
+// Scrape reads the project page named by source, which is a url, the path
+// of a saved page, or "-" for standard input.
+func (s *Scraper) Scrape(ctx context.Context, source string) (*model.Project, error) {
+	kind, err := Classify(source)
+	if err != nil {
+		return nil, err
+	}
+	switch kind {
+	case SourceStdin:
+		return s.ScrapeReader(ctx, os.Stdin, &url.URL{})
+	case SourceFile:
+		path, err := filepath.Abs(source)
+		if err != nil {
+			return nil, err
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		return s.ScrapeReader(ctx, file, &url.URL{Scheme: "file", Path: filepath.ToSlash(path)})
+	default:
+		return s.ScrapeURL(ctx, source)
+	}
+}
+
+// ScrapeURL fetches a project page, going through the page cache unless
+// NoCache is set.
+func (s *Scraper) ScrapeURL(ctx context.Context, pageURL string) (*model.Project, error) {
+	u, err := url.ParseRequestURI(pageURL)
+	if err != nil {
+		return nil, err
+	}
+	c := s.collector(ctx)
+	p := &parser{}
+	project := &model.Project{URL: u.String(), ScrapedAt: time.Now(), Questions: []model.Question{}}
+	c.OnHTML("html", func(page *colly.HTMLElement) {
+		project.Title = findTitle(page.DOM)
+	})
+	// Find and visit all question sections
+	c.OnHTML(".sect2", func(question *colly.HTMLElement) {
+		if q, ok := p.parseQuestion(question.DOM); ok {
+			project.Questions = append(project.Questions, q)
+		}
+	})
+	if err := c.Visit(u.String()); err != nil {
+		return nil, err
+	}
+	if err := s.finish(p, project, u); err != nil {
+		return nil, err
+	}
+	return project, nil
+}
+
+// ScrapeReader extracts a project from an already downloaded page. The page
+// URL is taken from BaseURL, then from the page's canonical link, and finally
+// falls back to the given URL.
+func (s *Scraper) ScrapeReader(ctx context.Context, r io.Reader, fallback *url.URL) (*model.Project, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return nil, err
+	}
+	u := fallback
+	if canonical, ok := doc.Find(`link[rel="canonical"]`).First().Attr("href"); ok {
+		if c, err := url.ParseRequestURI(canonical); err == nil && c.Host != "" {
+			u = c
+		}
+	}
+	if s.opts.BaseURL != "" {
+		u, err = url.ParseRequestURI(s.opts.BaseURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base url: %w", err)
+		}
+	}
+	p := &parser{}
+	project := &model.Project{
+		URL:       u.String(),
+		Title:     findTitle(doc.Selection),
+		ScrapedAt: time.Now(),
+		Questions: []model.Question{},
+	}
+	doc.Find(".sect2").Each(func(_ int, s *goquery.Selection) {
+		if q, ok := p.parseQuestion(s); ok {
+			project.Questions = append(project.Questions, q)
+		}
+	})
+	if err := s.finish(p, project, u); err != nil {
+		return nil, err
+	}
+	return project, nil
+}
+
+// finish reports the problems found while parsing, collects the datasets of
+// the questions and converts the question text to Markdown.
+func (s *Scraper) finish(p *parser, project *model.Project, base *url.URL) error {
+	if len(p.errs) > 0 && s.opts.Strict {
+		return p.errs
+	}
+	for _, e := range p.errs {
+		s.warn(e)
+	}
+	findDatasets(project.Questions)
+	if s.opts.KeepHTML {
+		return nil
+	}
+	stages, err := DefaultPipeline(base, s.opts.RewriteRules)
+	if err != nil {
+		return err
+	}
+	return RunPipeline(project.Questions, stages)
+}
+
+func (s *Scraper) warn(err error) {
+	if s.opts.Warn != nil {
+		s.opts.Warn(err)
+	}
+}
+
+// findTitle returns the title of a project page, which is its <h1>.
+func findTitle(page *goquery.Selection) string {
+	h1 := page.Find("h1.page")
+	if h1.Length() == 0 {
+		h1 = page.Find("h1")
+	}
+	return strings.TrimSpace(h1.First().Text())
+}
+
+// transport returns the transport for the requests made on behalf of ctx,
+// which goes through the cache in cacheDir unless NoCache is set. Requests
+// that reach the network pass the gate.
+func (s *Scraper) transport(ctx context.Context, cacheDir func() (string, error)) http.RoundTripper {
+	var transport http.RoundTripper = &politeTransport{
+		next:      http.DefaultTransport,
+		gate:      s.gate,
+		userAgent: s.userAgent(),
+		timeout:   s.opts.Timeout,
+		retries:   s.opts.Retries,
+	}
+	if !s.opts.NoCache {
+		transport = &cachingTransport{next: transport, dir: cacheDir, offline: s.opts.Offline, warn: s.warn}
+	}
+	return &contextTransport{ctx: ctx, next: transport}
+}
+
+// contextTransport ties every request of a collector to a context.
+type contextTransport struct {
+	ctx  context.Context
+	next http.RoundTripper
+}
+
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.next.RoundTrip(req.WithContext(t.ctx))
+}
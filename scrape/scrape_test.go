@@ -0,0 +1,95 @@
+/*
+Copyright © 2023 Mukul Agarwal
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package scrape
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+// golden compares got with the named file under testdata, or rewrites the
+// file with -update.
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs:\n%s", path, got)
+	}
+}
+
+func TestScrapeSavedPage(t *testing.T) {
+	var warnings []error
+	s := New(Options{Warn: func(err error) { warnings = append(warnings, err) }})
+	project, err := s.Scrape(context.Background(), filepath.Join("testdata", "project.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, w := range warnings {
+		t.Errorf("unexpected warning: %v", w)
+	}
+	project.ScrapedAt = time.Time{}
+	got, err := json.MarshalIndent(project, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden(t, "project.json", append(got, '\n'))
+}
+
+func TestScrapeBaseURL(t *testing.T) {
+	const base = "https://example.com/projects/project01"
+	page, err := os.Open(filepath.Join("testdata", "project.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer page.Close()
+	project, err := New(Options{BaseURL: base}).ScrapeReader(context.Background(), page, &url.URL{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if project.URL != base {
+		t.Errorf("got url %q, want %q", project.URL, base)
+	}
+}
+
+func TestScrapeStrict(t *testing.T) {
+	page := `<div class="sect2"><h3>Question 1</h3><div class="olist"><ol><li>no paragraph</li></ol></div></div>`
+	_, err := New(Options{Strict: true}).ScrapeReader(context.Background(), bytes.NewBufferString(page), &url.URL{})
+	var problems ParseErrors
+	if !errors.As(err, &problems) || len(problems) != 1 {
+		t.Fatalf("got %v, want one problem", err)
+	}
+}